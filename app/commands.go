@@ -0,0 +1,296 @@
+package main
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/config"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"Ralf/internal/prompts"
+	"Ralf/internal/service"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// maxListDescription — ширина колонки описания в ralf list.
+const maxListDescription = 70
+
+// newFlagSet создаёт набор флагов подкоманды: флаги всех настроек
+// и короткий --tasks для пути к файлу задач.
+func newFlagSet(name string, cfg *domen.Config) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	config.RegisterFlags(fs, cfg)
+	fs.StringVar(&cfg.TasksFilePath, "tasks", cfg.TasksFilePath, i18n.T("путь к файлу задач"))
+	return fs
+}
+
+// parseFlags разбирает флаги подкоманды и применяет язык из итоговых настроек.
+func parseFlags(fs *flag.FlagSet, cfg *domen.Config, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	lang, err := i18n.ParseLang(cfg.Language)
+	if err != nil {
+		return err
+	}
+	i18n.SetLanguage(lang)
+	return nil
+}
+
+// runCommand — ralf run: обработка задач оркестратором.
+func runCommand(cfg domen.Config, source string, args []string) error {
+	fs := newFlagSet("run", &cfg)
+	var filter domen.TaskFilter
+	var onlyStatus string
+	fs.IntVar(&filter.Num, "task", 0, i18n.T("обработать только задачу с этим номером"))
+	fs.IntVar(&filter.From, "from", 0, i18n.T("обрабатывать задачи начиная с этого номера"))
+	fs.StringVar(&onlyStatus, "only-status", "", i18n.T("статусы задач через запятую (по умолчанию new)"))
+	if err := parseFlags(fs, &cfg, args); err != nil {
+		return err
+	}
+	statuses, err := parseStatuses(onlyStatus)
+	if err != nil {
+		return err
+	}
+	filter.Statuses = statuses
+
+	closeLog, err := logging.Setup(cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	if err := config.Print(os.Stdout, cfg, source); err != nil {
+		return err
+	}
+	// Первый Ctrl-C (или SIGTERM) аккуратно останавливает обработку: текущий
+	// запрос к модели или сборка прерываются, изменения задачи откатываются.
+	// Повторный сигнал завершает процесс сразу.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := service.RunOrchestrator(ctx, cfg, filter); err != nil {
+		return fmt.Errorf(i18n.T("оркестратор завершился ошибкой: %w"), err)
+	}
+	fmt.Println(i18n.T("Все задачи обработаны успешно."))
+	return nil
+}
+
+// parseStatuses разбирает список статусов вида "new,error".
+func parseStatuses(list string) ([]domen.TaskStatus, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var statuses []domen.TaskStatus
+	for _, part := range strings.Split(list, ",") {
+		status := domen.TaskStatus(strings.TrimSpace(part))
+		if !status.Valid() {
+			return nil, fmt.Errorf(i18n.T("недопустимый статус %q (допустимо: new, run, error, ok)"), status)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// listCommand — ralf list: таблица задач и статусов.
+func listCommand(cfg domen.Config, args []string) error {
+	fs := newFlagSet("list", &cfg)
+	if err := parseFlags(fs, &cfg, args); err != nil {
+		return err
+	}
+	tasks, err := service.ReadTasks(cfg.TasksFilePath)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, i18n.T("№\tСТАТУС\tОПИСАНИЕ"))
+	counts := make(map[domen.TaskStatus]int)
+	for _, task := range tasks {
+		counts[task.Status]++
+		fmt.Fprintf(w, "%d\t%s\t%s\n", task.Num, task.Status, shorten(task.Description, maxListDescription))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf(i18n.T("Всего: %d (new: %d, run: %d, error: %d, ok: %d)\n"), len(tasks),
+		counts[domen.StatusNew], counts[domen.StatusRun], counts[domen.StatusError], counts[domen.StatusOK])
+	return nil
+}
+
+// shorten обрезает строку до limit символов.
+func shorten(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit-1]) + "…"
+}
+
+// showCommand — ralf show N: все поля задачи.
+func showCommand(cfg domen.Config, args []string) error {
+	fs := newFlagSet("show", &cfg)
+	if err := parseFlags(fs, &cfg, args); err != nil {
+		return err
+	}
+	num, err := taskNumArg(fs)
+	if err != nil {
+		return err
+	}
+	task, err := service.FindTask(cfg.TasksFilePath, num)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(i18n.T("Задача №%d\n"), task.Num)
+	fmt.Printf(i18n.T("Статус: %s\n"), task.Status)
+	fmt.Printf(i18n.T("Описание: %s\n"), task.Description)
+	fmt.Printf(i18n.T("Важные моменты: %s\n"), task.ImportantInfo)
+	fmt.Printf(i18n.T("Ожидаемый результат: %s\n"), task.ExpectResult)
+	fmt.Printf(i18n.T("Тестовые данные: %s\n"), task.TestsValue)
+	fmt.Printf(i18n.T("Сигнатура функции: %s\n"), task.FuncSignature)
+	if task.Attempts != 0 {
+		fmt.Printf(i18n.T("Попыток: %d\n"), task.Attempts)
+	}
+	return nil
+}
+
+// taskNumArg возвращает номер задачи из единственного позиционного аргумента.
+func taskNumArg(fs *flag.FlagSet) (int, error) {
+	if fs.NArg() != 1 {
+		return 0, errors.New(i18n.T("нужно указать номер задачи"))
+	}
+	num, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return 0, fmt.Errorf(i18n.T("неверный формат номера задачи: %w"), err)
+	}
+	return num, nil
+}
+
+// resetCommand — ralf reset N | --all-errors: вернуть задачи в статус new.
+func resetCommand(cfg domen.Config, args []string) error {
+	fs := newFlagSet("reset", &cfg)
+	allErrors := fs.Bool("all-errors", false, i18n.T("вернуть в new все задачи со статусом error"))
+	if err := parseFlags(fs, &cfg, args); err != nil {
+		return err
+	}
+
+	var nums []int
+	if *allErrors {
+		tasks, err := service.ReadTasks(cfg.TasksFilePath)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if task.Status == domen.StatusError {
+				nums = append(nums, task.Num)
+			}
+		}
+	} else {
+		num, err := taskNumArg(fs)
+		if err != nil {
+			return err
+		}
+		nums = append(nums, num)
+	}
+
+	for _, num := range nums {
+		if err := service.UpdateTaskStatus(cfg.TasksFilePath, num, domen.StatusNew); err != nil {
+			return err
+		}
+		fmt.Printf(i18n.T("Задача %d возвращена в статус new.\n"), num)
+	}
+	if len(nums) == 0 {
+		fmt.Println(i18n.T("Нет задач для сброса."))
+	}
+	return nil
+}
+
+// addCommand — ralf add: добавить задачу из флагов, а если описание
+// не задано — спросить поля интерактивно.
+func addCommand(cfg domen.Config, args []string, in io.Reader) error {
+	fs := newFlagSet("add", &cfg)
+	var task domen.Task
+	fs.StringVar(&task.Description, "description", "", i18n.T("описание задачи"))
+	fs.StringVar(&task.ImportantInfo, "important", "", i18n.T("важные моменты"))
+	fs.StringVar(&task.ExpectResult, "expect", "", i18n.T("ожидаемый результат"))
+	fs.StringVar(&task.TestsValue, "tests-data", "", i18n.T("тестовые данные"))
+	fs.StringVar(&task.FuncSignature, "signature", "", i18n.T("сигнатура функции"))
+	if err := parseFlags(fs, &cfg, args); err != nil {
+		return err
+	}
+
+	if task.Description == "" {
+		reader := bufio.NewReader(in)
+		fields := []struct {
+			prompt string
+			value  *string
+		}{
+			{i18n.T("Описание задачи: "), &task.Description},
+			{i18n.T("Важные моменты: "), &task.ImportantInfo},
+			{i18n.T("Ожидаемый результат: "), &task.ExpectResult},
+			{i18n.T("Тестовые данные: "), &task.TestsValue},
+			{i18n.T("Сигнатура функции (можно пусто): "), &task.FuncSignature},
+		}
+		for _, f := range fields {
+			fmt.Print(f.prompt)
+			line, err := reader.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			*f.value = strings.TrimSpace(line)
+		}
+	}
+	if strings.TrimSpace(task.Description) == "" {
+		return errors.New(i18n.T("описание задачи не может быть пустым"))
+	}
+
+	added, err := service.AppendTask(cfg.TasksFilePath, task)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(i18n.T("Добавлена задача №%d.\n"), added.Num)
+	return nil
+}
+
+// validateCommand — ralf validate: проверка файла задач и шаблонов промптов.
+func validateCommand(cfg domen.Config, args []string) error {
+	fs := newFlagSet("validate", &cfg)
+	fs.StringVar(&cfg.PromptsDir, "prompts", cfg.PromptsDir, i18n.T("каталог шаблонов промптов"))
+	if err := parseFlags(fs, &cfg, args); err != nil {
+		return err
+	}
+
+	var errs []error
+	if err := service.ValidateTasks(cfg.TasksFilePath); err != nil {
+		errs = append(errs, fmt.Errorf(i18n.T("файл задач %s: %w"), cfg.TasksFilePath, err))
+	} else {
+		fmt.Printf(i18n.T("Файл задач %s в порядке.\n"), cfg.TasksFilePath)
+	}
+	if err := prompts.Validate(cfg.PromptsDir); err != nil {
+		errs = append(errs, fmt.Errorf(i18n.T("шаблоны промптов: %w"), err))
+	} else {
+		fmt.Println(i18n.T("Шаблоны промптов в порядке."))
+	}
+	return errors.Join(errs...)
+}
+
+// configCommand — ralf config: действующие настройки с учётом файла, окружения и флагов.
+func configCommand(cfg domen.Config, source string, args []string) error {
+	fs := newFlagSet("config", &cfg)
+	if err := parseFlags(fs, &cfg, args); err != nil {
+		return err
+	}
+	return config.Print(os.Stdout, cfg, source)
+}
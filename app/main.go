@@ -1,23 +1,84 @@
 package main
 
 import (
-	"Ralf/domen"
-	"Ralf/internal/service"
+	"Ralf/internal/config"
+	"Ralf/internal/i18n"
 	"fmt"
 	"os"
 )
 
+// usage — справка по подкомандам.
+const usage = `Использование: ralf <команда> [флаги]
+
+Команды:
+  run       обработать задачи (--task N, --from N, --only-status new,error)
+  list      таблица задач и их статусов
+  show N    подробности задачи N
+  reset N   вернуть задачу N в статус new (--all-errors — все задачи со статусом error)
+  add       добавить задачу (из флагов или интерактивно)
+  validate  проверить файл задач и шаблоны промптов
+  config    показать действующие настройки
+
+Без команды выполняется run.
+
+Настройки читаются из ralf.yaml или ralf.toml в текущем каталоге, затем
+из переменных окружения RALF_<КЛЮЧ> (например, RALF_MODEL) и флагов
+--<ключ> (например, --model, --output-dir). Список ключей: ralf config.`
+
 func main() {
-	cfg := domen.Config{
-		TasksFilePath:         "tasks.txt",
-		MaxTaskAttempts:       5,
-		MaxCompileFixAttempts: 5,
-		MaxTestAttempts:       5,
-		WorkingDir:            ".",
+	// Настройки: значения по умолчанию ← ralf.yaml/ralf.toml ← RALF_*; флаги
+	// подкоманды применяются поверх при разборе аргументов.
+	cfg, source, err := config.Load(".")
+	if lang, langErr := i18n.ParseLang(cfg.Language); langErr == nil {
+		i18n.SetLanguage(lang)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, i18n.T("Ошибка: %v\n"), err)
+		os.Exit(1)
 	}
-	if err := service.RunOrchestrator(cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "Оркестратор завершился ошибкой: %v\n", err)
+
+	command, args := splitCommand(os.Args[1:])
+	switch command {
+	case "run":
+		err = runCommand(cfg, source, args)
+	case "list":
+		err = listCommand(cfg, args)
+	case "show":
+		err = showCommand(cfg, args)
+	case "reset":
+		err = resetCommand(cfg, args)
+	case "add":
+		err = addCommand(cfg, args, os.Stdin)
+	case "validate":
+		err = validateCommand(cfg, args)
+	case "config":
+		err = configCommand(cfg, source, args)
+	case "help":
+		fmt.Println(i18n.T(usage))
+		return
+	default:
+		fmt.Fprintf(os.Stderr, i18n.T("Неизвестная команда: %s\n\n"), command)
+		fmt.Fprintln(os.Stderr, i18n.T(usage))
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, i18n.T("Ошибка: %v\n"), err)
 		os.Exit(1)
 	}
-	fmt.Println("Все задачи обработаны успешно.")
+}
+
+// splitCommand выделяет подкоманду из аргументов. Без подкоманды (аргументы
+// пусты или начинаются с флага) выполняется run, но -h и --help всегда
+// означают справку, а не флаги run.
+func splitCommand(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "run", args
+	}
+	switch first := args[0]; {
+	case first == "-h" || first == "--help" || first == "-help":
+		return "help", args[1:]
+	case first != "" && first[0] != '-':
+		return first, args[1:]
+	}
+	return "run", args
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_splitCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantCommand string
+		wantArgs    []string
+	}{
+		{name: "no arguments", args: nil, wantCommand: "run"},
+		{name: "subcommand", args: []string{"list", "--status", "new"}, wantCommand: "list", wantArgs: []string{"--status", "new"}},
+		{name: "run flags without subcommand", args: []string{"--model", "m"}, wantCommand: "run", wantArgs: []string{"--model", "m"}},
+		{name: "short help flag", args: []string{"-h"}, wantCommand: "help", wantArgs: []string{}},
+		{name: "long help flag", args: []string{"--help"}, wantCommand: "help", wantArgs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, args := splitCommand(tt.args)
+			if command != tt.wantCommand || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("splitCommand() = %q, %q, want %q, %q", command, args, tt.wantCommand, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -15,6 +15,33 @@ const (
 	CmdCompileCode CommandType = "компиляция"
 )
 
+// CommandTypes — все допустимые типы команд в порядке, в котором они перечисляются в промптах.
+var CommandTypes = []CommandType{
+	CmdCreate,
+	CmdDelete,
+	CmdEdit,
+	CmdAddLines,
+	CmdDeleteLines,
+	CmdCopy,
+	CmdMove,
+	CmdRead,
+	CmdCompileCode,
+}
+
+// EnglishCommandTypes — английские названия типов команд. Используются в промптах
+// на английском и принимаются от модели как синонимы русских.
+var EnglishCommandTypes = map[CommandType]string{
+	CmdCreate:      "create",
+	CmdDelete:      "delete",
+	CmdEdit:        "edit",
+	CmdCopy:        "copy",
+	CmdMove:        "move",
+	CmdRead:        "read",
+	CmdAddLines:    "add_lines",
+	CmdDeleteLines: "delete_lines",
+	CmdCompileCode: "compile",
+}
+
 // Command представляет одну атомарную команду для модификации файловой системы.
 type Command struct {
 	Type    string            `json:"Type"`
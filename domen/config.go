@@ -1,9 +1,184 @@
 package domen
 
+import "time"
+
+// Config — настройки запуска. Тег config задаёт ключ в файле ralf.yaml/ralf.toml;
+// из него же получаются имя переменной окружения (RALF_<КЛЮЧ>) и флага (--ключ).
 type Config struct {
-	TasksFilePath         string // путь к файлу задач
-	MaxTaskAttempts       int    // максимум попыток на одну задачу (общий цикл)
-	MaxCompileFixAttempts int    // максимум циклов исправления компиляции
-	MaxTestAttempts       int    // максимум попыток генерации тестов
-	WorkingDir            string // рабочая директория проекта
+	TasksFilePath         string        `config:"tasks_file"`             // путь к файлу задач
+	MaxTaskAttempts       int           `config:"max_task_attempts"`      // максимум попыток на одну задачу (общий цикл; по умолчанию 1 — без повторов, каждая повторная попытка стоит ещё одного прохода всех этапов)
+	RetryTemperatureStep  float64       `config:"retry_temperature_step"` // на сколько повышать температуру модели с каждой повторной попыткой задачи (отрицательное — не повышать)
+	RetryModels           string        `config:"retry_models"`           // модели для повторных попыток задачи через запятую, как в цепочках (пусто — та же модель)
+	Candidates            int           `config:"candidates"`             // сколько вариантов решения запрашивать у модели, чтобы выбрать лучший по сборке и тестам (1 — без выбора)
+	Plan                  string        `config:"plan"`                   // планирование: off или on (модель разбивает задачу на подзадачи и они пишутся в файл задач)
+	MaxSubtasks           int           `config:"max_subtasks"`           // наибольшее число подзадач в плане
+	MinCoverage           float64       `config:"min_coverage"`           // требуемое покрытие тестами изменённых задачей файлов, % (0 — только измерять)
+	MaxCoverageRounds     int           `config:"max_coverage_rounds"`    // максимум раундов дополнения тестов до min_coverage
+	Format                string        `config:"format"`                 // обработка .go-файлов после записи: off, on (go/format) или imports (ещё и исправление импортов)
+	Lint                  string        `config:"lint"`                   // проверки качества через запятую: gofmt, vet или анализатор из PATH (staticcheck), с политикой :fix или :report (пусто — без проверок)
+	Review                string        `config:"review"`                 // ревью решения моделью перед статусом ok: off или on
+	MaxReviewFixes        int           `config:"max_review_fixes"`       // максимум доработок по замечаниям ревью
+	MaxCompileFixAttempts int           `config:"max_compile_fixes"`      // максимум циклов исправления компиляции
+	MaxTestAttempts       int           `config:"max_test_attempts"`      // максимум попыток генерации тестов
+	WorkingDir            string        `config:"working_dir"`            // рабочая директория проекта
+	OutputDir             string        `config:"output_dir"`             // каталог внутри проекта, куда модель пишет код
+	SandboxRoot           string        `config:"sandbox_root"`           // каталог, за пределы которого команды модели не выходят
+	ContextSize           int           `config:"context_size"`           // размер контекста модели в токенах (0 — по таблице моделей)
+	CharsPerToken         float64       `config:"chars_per_token"`        // символов на токен для оценки размера запроса (0 — по умолчанию)
+	PromptsDir            string        `config:"prompts_dir"`            // каталог с переопределёнными шаблонами промптов (*.tmpl)
+	Language              string        `config:"language"`               // язык промптов, типов команд и сообщений: ru или en
+	Endpoint              string        `config:"endpoint"`               // адрес OpenAI-совместимого API
+	Model                 string        `config:"model"`                  // имя модели в LM Studio
+	SolveModels           string        `config:"solve_models"`           // цепочка моделей этапа solve через запятую, от быстрой к мощной (модель на другом API: имя@http://адрес)
+	CompileModels         string        `config:"compile_models"`         // цепочка моделей исправления сборки
+	TestsModels           string        `config:"tests_models"`           // цепочка моделей генерации тестов
+	TestCompileModels     string        `config:"test_compile_models"`    // цепочка моделей исправления сборки тестов
+	EscalateAfter         int           `config:"escalate_after"`         // после скольких неудачных исправлений переходить к следующей модели цепочки (отрицательное — не переходить)
+	RequestTimeout        time.Duration `config:"request_timeout"`        // таймаут одного запроса к LLM
+	CompileTimeout        time.Duration `config:"compile_timeout"`        // таймаут одного запуска go build / go test
+	TaskTimeout           time.Duration `config:"task_timeout"`           // лимит времени на задачу целиком
+	StageTimeout          time.Duration `config:"stage_timeout"`          // лимит времени на один этап задачи (0 — без ограничения)
+	MaxTaskTokens         int           `config:"max_task_tokens"`        // лимит токенов LLM на задачу (0 — без ограничения)
+	MaxRunTokens          int           `config:"max_run_tokens"`         // лимит токенов LLM на весь запуск (0 — без ограничения)
+	LLMMode               string        `config:"llm_mode"`               // режим LLM: live, record (запись в кассету) или replay (из кассеты)
+	CassetteDir           string        `config:"cassette_dir"`           // каталог кассет с записанными ответами LLM
+	GitMode               string        `config:"git"`                    // git: off, commit (коммит в текущую ветку), branch (ветка для ревью), merge (ветка с вливанием)
+	Workers               int           `config:"workers"`                // сколько задач выполнять одновременно (каждая в своей копии проекта)
+	ReportDir             string        `config:"report_dir"`             // каталог отчётов о запусках (JSON и Markdown)
+	LogLevel              string        `config:"log_level"`              // уровень журнала: debug, info, warn или error
+	LogFormat             string        `config:"log_format"`             // формат журнала: text или json
+	PromptLog             string        `config:"prompt_log"`             // файл для полных промптов и ответов модели (пусто — не писать)
+}
+
+// DefaultConfig возвращает настройки по умолчанию — единственное место,
+// где они заданы.
+func DefaultConfig() Config {
+	return Config{
+		TasksFilePath:         "tasks.txt",
+		MaxTaskAttempts:       1,
+		RetryTemperatureStep:  0.2,
+		Candidates:            1,
+		Plan:                  "off",
+		MaxSubtasks:           5,
+		MaxCoverageRounds:     3,
+		Format:                "on",
+		Review:                "off",
+		MaxReviewFixes:        2,
+		MaxCompileFixAttempts: 5,
+		MaxTestAttempts:       5,
+		WorkingDir:            ".",
+		OutputDir:             "prog",
+		PromptsDir:            "prompts",
+		Language:              "ru",
+		Endpoint:              "http://localhost:1234/v1",
+		Model:                 "local-model",
+		EscalateAfter:         2,
+		RequestTimeout:        300 * time.Second,
+		CompileTimeout:        2 * time.Minute,
+		TaskTimeout:           30 * time.Minute,
+		LLMMode:               "live",
+		CassetteDir:           "cassettes",
+		GitMode:               "off",
+		Workers:               1,
+		ReportDir:             "reports",
+		LogLevel:              "info",
+		LogFormat:             "text",
+	}
+}
+
+// WithDefaults возвращает копию настроек, в которой незаданные (нулевые) поля
+// заполнены значениями по умолчанию. ContextSize, CharsPerToken, SandboxRoot,
+// PromptsDir, PromptLog, RetryModels и цепочки моделей этапов остаются пустыми:
+// для них это означает «определить автоматически», «только встроенные шаблоны»,
+// «не писать» или «модель Model». Нулевые StageTimeout, MaxTaskTokens и MaxRunTokens означают
+// «без ограничения». Ноль в RetryTemperatureStep и EscalateAfter тоже означает
+// значение по умолчанию, поэтому выключаются они отрицательным значением;
+// повторы задачи выключаются MaxTaskAttempts = 1.
+func (c Config) WithDefaults() Config {
+	d := DefaultConfig()
+	if c.TasksFilePath == "" {
+		c.TasksFilePath = d.TasksFilePath
+	}
+	if c.MaxTaskAttempts == 0 {
+		c.MaxTaskAttempts = d.MaxTaskAttempts
+	}
+	if c.RetryTemperatureStep == 0 {
+		c.RetryTemperatureStep = d.RetryTemperatureStep
+	}
+	if c.Candidates <= 0 {
+		c.Candidates = d.Candidates
+	}
+	if c.Plan == "" {
+		c.Plan = d.Plan
+	}
+	if c.MaxSubtasks <= 0 {
+		c.MaxSubtasks = d.MaxSubtasks
+	}
+	if c.MaxCoverageRounds <= 0 {
+		c.MaxCoverageRounds = d.MaxCoverageRounds
+	}
+	if c.Format == "" {
+		c.Format = d.Format
+	}
+	if c.Review == "" {
+		c.Review = d.Review
+	}
+	if c.MaxReviewFixes <= 0 {
+		c.MaxReviewFixes = d.MaxReviewFixes
+	}
+	if c.MaxCompileFixAttempts == 0 {
+		c.MaxCompileFixAttempts = d.MaxCompileFixAttempts
+	}
+	if c.MaxTestAttempts == 0 {
+		c.MaxTestAttempts = d.MaxTestAttempts
+	}
+	if c.WorkingDir == "" {
+		c.WorkingDir = d.WorkingDir
+	}
+	if c.OutputDir == "" {
+		c.OutputDir = d.OutputDir
+	}
+	if c.Language == "" {
+		c.Language = d.Language
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = d.Endpoint
+	}
+	if c.Model == "" {
+		c.Model = d.Model
+	}
+	if c.EscalateAfter == 0 {
+		c.EscalateAfter = d.EscalateAfter
+	}
+	if c.RequestTimeout == 0 {
+		c.RequestTimeout = d.RequestTimeout
+	}
+	if c.CompileTimeout == 0 {
+		c.CompileTimeout = d.CompileTimeout
+	}
+	if c.TaskTimeout == 0 {
+		c.TaskTimeout = d.TaskTimeout
+	}
+	if c.LLMMode == "" {
+		c.LLMMode = d.LLMMode
+	}
+	if c.CassetteDir == "" {
+		c.CassetteDir = d.CassetteDir
+	}
+	if c.GitMode == "" {
+		c.GitMode = d.GitMode
+	}
+	if c.Workers <= 0 {
+		c.Workers = d.Workers
+	}
+	if c.ReportDir == "" {
+		c.ReportDir = d.ReportDir
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = d.LogLevel
+	}
+	if c.LogFormat == "" {
+		c.LogFormat = d.LogFormat
+	}
+	return c
 }
@@ -19,4 +19,46 @@ type Task struct {
 	TestsValue    string     // тестовые данные
 	FuncSignature string     // сигнатура функции (может быть пустой)
 	Status        TaskStatus // текущий статус
+	Parent        int        // номер родительской задачи (0 — задача верхнего уровня)
+	Attempts      int        // сколько попыток ушло на задачу в последнем запуске (0 — не запускалась)
+}
+
+// Valid сообщает, что статус входит в число допустимых.
+func (s TaskStatus) Valid() bool {
+	switch s {
+	case StatusNew, StatusRun, StatusError, StatusOK:
+		return true
+	}
+	return false
+}
+
+// TaskFilter определяет, какие задачи обрабатывать в запуске.
+type TaskFilter struct {
+	Num      int          // только задача с этим номером (0 — любая)
+	From     int          // задачи с номером не меньше From (0 — с первой)
+	Statuses []TaskStatus // допустимые статусы (пусто — только new)
+}
+
+// Match сообщает, подходит ли задача под фильтр. Подзадачи выполняются
+// в составе родительской задачи, поэтому подходят, только если выбраны по номеру.
+func (f TaskFilter) Match(task Task) bool {
+	if f.Num != 0 && task.Num != f.Num {
+		return false
+	}
+	if task.Parent != 0 && f.Num == 0 {
+		return false
+	}
+	if task.Num < f.From {
+		return false
+	}
+	statuses := f.Statuses
+	if len(statuses) == 0 {
+		statuses = []TaskStatus{StatusNew}
+	}
+	for _, s := range statuses {
+		if task.Status == s {
+			return true
+		}
+	}
+	return false
 }
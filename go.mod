@@ -1,3 +1,8 @@
 module Ralf
 
 go 1.25.4
+
+require (
+	github.com/pelletier/go-toml/v2 v2.2.4
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,199 @@
+// Package config собирает настройки запуска из нескольких источников.
+// Приоритет (от низшего к высшему): значения по умолчанию, файл ralf.yaml
+// или ralf.toml в рабочем каталоге, переменные окружения RALF_*, флаги командной строки.
+package config
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix — префикс переменных окружения с настройками.
+const EnvPrefix = "RALF_"
+
+// FileNames — имена файла настроек в порядке поиска.
+var FileNames = []string{"ralf.yaml", "ralf.yml", "ralf.toml"}
+
+// field — настраиваемое поле domen.Config.
+type field struct {
+	Key   string // ключ в файле настроек: tasks_file
+	Index int    // номер поля в структуре
+}
+
+// Env возвращает имя переменной окружения: RALF_TASKS_FILE.
+func (f field) Env() string {
+	return EnvPrefix + strings.ToUpper(f.Key)
+}
+
+// Flag возвращает имя флага: tasks-file.
+func (f field) Flag() string {
+	return strings.ReplaceAll(f.Key, "_", "-")
+}
+
+// fields перечисляет поля domen.Config с тегом config в порядке объявления.
+func fields() []field {
+	t := reflect.TypeOf(domen.Config{})
+	result := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("config"); key != "" {
+			result = append(result, field{Key: key, Index: i})
+		}
+	}
+	return result
+}
+
+// Load возвращает настройки по умолчанию, дополненные файлом настроек из dir
+// и переменными окружения RALF_*. Второе значение — путь к прочитанному файлу
+// (пусто, если файла нет).
+func Load(dir string) (domen.Config, string, error) {
+	cfg := domen.DefaultConfig()
+	path, err := loadFile(dir, &cfg)
+	if err != nil {
+		return cfg, path, err
+	}
+	if err := loadEnv(os.LookupEnv, &cfg); err != nil {
+		return cfg, path, err
+	}
+	return cfg, path, nil
+}
+
+// loadFile читает первый найденный файл настроек из dir.
+func loadFile(dir string, cfg *domen.Config) (string, error) {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return path, fmt.Errorf(i18n.T("не удалось прочитать файл настроек: %w"), err)
+		}
+
+		values := make(map[string]any)
+		if filepath.Ext(name) == ".toml" {
+			err = toml.Unmarshal(data, &values)
+		} else {
+			err = yaml.Unmarshal(data, &values)
+		}
+		if err != nil {
+			return path, fmt.Errorf(i18n.T("ошибка разбора файла настроек %s: %w"), path, err)
+		}
+		if err := apply(values, cfg); err != nil {
+			return path, fmt.Errorf(i18n.T("файл настроек %s: %w"), path, err)
+		}
+		return path, nil
+	}
+	return "", nil
+}
+
+// apply записывает значения из файла в настройки. Неизвестные ключи — ошибка,
+// чтобы опечатка в имени настройки не терялась молча.
+func apply(values map[string]any, cfg *domen.Config) error {
+	byKey := make(map[string]field)
+	for _, f := range fields() {
+		byKey[f.Key] = f
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		f, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf(i18n.T("неизвестная настройка %q"), key))
+			continue
+		}
+		if err := set(cfg, f, fmt.Sprint(values[key])); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadEnv применяет переменные окружения RALF_<КЛЮЧ>.
+func loadEnv(lookup func(string) (string, bool), cfg *domen.Config) error {
+	var errs []error
+	for _, f := range fields() {
+		if value, ok := lookup(f.Env()); ok {
+			if err := set(cfg, f, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.Env(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterFlags добавляет в набор флагов по флагу на каждую настройку.
+// Значения флагов записываются прямо в cfg при разборе.
+func RegisterFlags(fs *flag.FlagSet, cfg *domen.Config) {
+	for _, f := range fields() {
+		usage := fmt.Sprintf(i18n.T("настройка %s (переменная %s)"), f.Key, f.Env())
+		fs.Func(f.Flag(), usage, func(value string) error {
+			return set(cfg, f, value)
+		})
+	}
+}
+
+// set разбирает строковое значение по типу поля.
+func set(cfg *domen.Config, f field, raw string) error {
+	v := reflect.ValueOf(cfg).Elem().Field(f.Index)
+	raw = strings.TrimSpace(raw)
+	var err error
+	switch v.Interface().(type) {
+	case string:
+		v.SetString(raw)
+	case int:
+		var n int
+		if n, err = strconv.Atoi(raw); err == nil {
+			v.SetInt(int64(n))
+		}
+	case float64:
+		var x float64
+		if x, err = strconv.ParseFloat(raw, 64); err == nil {
+			v.SetFloat(x)
+		}
+	case time.Duration:
+		var d time.Duration
+		if d, err = time.ParseDuration(raw); err == nil {
+			v.SetInt(int64(d))
+		}
+	default:
+		err = fmt.Errorf(i18n.T("неподдерживаемый тип %s"), v.Type())
+	}
+	if err != nil {
+		return fmt.Errorf(i18n.T("неверное значение %q для %s: %w"), raw, f.Key, err)
+	}
+	return nil
+}
+
+// Print выводит действующие настройки в формате «ключ значение».
+func Print(w io.Writer, cfg domen.Config, source string) error {
+	if source == "" {
+		source = i18n.T("нет, используются значения по умолчанию")
+	}
+	fmt.Fprintf(w, i18n.T("Файл настроек: %s\n"), source)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	v := reflect.ValueOf(cfg)
+	for _, f := range fields() {
+		fmt.Fprintf(tw, "  %s\t%v\n", f.Key, v.Field(f.Index).Interface())
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"Ralf/domen"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		check   func(t *testing.T, cfg domen.Config)
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg domen.Config) {
+				if cfg != domen.DefaultConfig() {
+					t.Errorf("Load() = %+v, want defaults", cfg)
+				}
+			},
+		},
+		{
+			name:    "yaml",
+			file:    "ralf.yaml",
+			content: "model: qwen2.5-coder\nmax_compile_fixes: 7\nrequest_timeout: 90s\nchars_per_token: 2.5\noutput_dir: gen\n",
+			check: func(t *testing.T, cfg domen.Config) {
+				if cfg.Model != "qwen2.5-coder" || cfg.MaxCompileFixAttempts != 7 ||
+					cfg.RequestTimeout != 90*time.Second || cfg.CharsPerToken != 2.5 || cfg.OutputDir != "gen" {
+					t.Errorf("Load() = %+v", cfg)
+				}
+				if cfg.MaxTestAttempts != domen.DefaultConfig().MaxTestAttempts {
+					t.Errorf("Load() lost default MaxTestAttempts: %d", cfg.MaxTestAttempts)
+				}
+			},
+		},
+		{
+			name:    "toml",
+			file:    "ralf.toml",
+			content: "endpoint = \"http://gpu:1234/v1\"\ncontext_size = 8192\ncompile_timeout = \"30s\"\n",
+			check: func(t *testing.T, cfg domen.Config) {
+				if cfg.Endpoint != "http://gpu:1234/v1" || cfg.ContextSize != 8192 || cfg.CompileTimeout != 30*time.Second {
+					t.Errorf("Load() = %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "env overrides file",
+			file:    "ralf.yaml",
+			content: "model: from-file\nlanguage: ru\n",
+			env:     map[string]string{"RALF_MODEL": "from-env"},
+			check: func(t *testing.T, cfg domen.Config) {
+				if cfg.Model != "from-env" || cfg.Language != "ru" {
+					t.Errorf("Load() = %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "unknown key",
+			file:    "ralf.yaml",
+			content: "modle: typo\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad value",
+			file:    "ralf.yaml",
+			content: "max_test_attempts: many\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad env",
+			env:     map[string]string{"RALF_REQUEST_TIMEOUT": "300"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, source, err := Load(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.file != "" && filepath.Base(source) != tt.file {
+				t.Errorf("Load() source = %q, want %q", source, tt.file)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	cfg := domen.DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs, &cfg)
+
+	if err := fs.Parse([]string{"--model", "cli-model", "--max-task-attempts", "2", "--sandbox-root", "/tmp/box"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cfg.Model != "cli-model" || cfg.MaxTaskAttempts != 2 || cfg.SandboxRoot != "/tmp/box" {
+		t.Errorf("flags not applied: %+v", cfg)
+	}
+	if err := fs.Parse([]string{"--compile-timeout", "soon"}); err == nil {
+		t.Errorf("Parse() with invalid duration: want error")
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var sb strings.Builder
+	if err := Print(&sb, domen.DefaultConfig(), "ralf.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	out := sb.String()
+	for _, want := range []string{"ralf.yaml", "model", "local-model", "output_dir", "prog", "request_timeout", "5m0s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Print() output has no %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package i18n
+
+// english — английские переводы сообщений. Ключ — исходный русский текст.
+var english = map[string]string{
+	// ошибки языка и шаблонов
+	"неизвестный язык: %q (допустимо: ru, en)":    "unknown language: %q (allowed: ru, en)",
+	"не удалось прочитать шаблон %s: %w":          "failed to read template %s: %w",
+	"нет встроенного шаблона %s для языка %s: %w": "no built-in template %s for language %s: %w",
+	"ошибка разбора шаблона %s: %w":               "failed to parse template %s: %w",
+	"ошибка шаблона %s: %w":                       "template %s failed: %w",
+	"не удалось загрузить шаблоны промптов: %w":   "failed to load prompt templates: %w",
+	"Шаблоны промптов содержат ошибки: %v\n":      "Prompt templates contain errors: %v\n",
+	"Шаблоны промптов в порядке.":                 "Prompt templates are valid.",
+
+	// файл задач
+	"не удалось открыть файл задач: %w":                                             "failed to open tasks file: %w",
+	"ошибка парсинга задачи: %w":                                                    "failed to parse task: %w",
+	"ошибка чтения файла: %w":                                                       "failed to read file: %w",
+	"не найдено задач со статусом new":                                              "no tasks with status new found",
+	"неверный формат номера задачи: %w":                                             "invalid task number format: %w",
+	"не удалось открыть файл для чтения: %w":                                        "failed to open file for reading: %w",
+	"не удалось создать временный файл: %w":                                         "failed to create temporary file: %w",
+	"ошибка записи во временный файл: %w":                                           "failed to write temporary file: %w",
+	"ошибка чтения исходного файла: %w":                                             "failed to read source file: %w",
+	"не удалось заменить исходный файл: %w":                                         "failed to replace source file: %w",
+	"не получилось поменять статус задачи № %d в файле %s: задача не найдена":       "failed to change status of task #%d in file %s: task not found",
+	"не получилось поменять статус задачи № %d в файле %s: поле статуса не найдено": "failed to change status of task #%d in file %s: status field not found",
+
+	// LM Studio
+	"не удалось маршалировать запрос: %w":                           "failed to marshal request: %w",
+	"ошибка соединения с LM Studio: %w":                             "failed to connect to LM Studio: %w",
+	"LM Studio вернул код %d: %s":                                   "LM Studio returned status %d: %s",
+	"ошибка парсинга JSON ответа: %w":                               "failed to parse JSON response: %w",
+	"LM Studio вернул пустой ответ":                                 "LM Studio returned an empty response",
+	"ошибка парсинга JSON: %w":                                      "failed to parse JSON: %w",
+	"в ответе LM Studio не обнаружено ни одной команды":             "no commands found in the LM Studio response",
+	"Попытка исправления №%d, файл %s.\nЛог ошибки компиляции:\n%s": "Fix attempt #%d, file %s.\nCompilation error log:\n%s",
+
+	// кассеты
+	"в кассете нет ответа для запроса":                            "no recorded response in the cassette for the request",
+	"неизвестный режим LLM: %q (допустимо: live, record, replay)": "unknown LLM mode: %q (allowed: live, record, replay)",
+	"повреждён файл кассеты %s: %w":                               "corrupted cassette file %s: %w",
+	"не удалось создать каталог кассеты: %w":                      "failed to create cassette directory: %w",
+	"не удалось записать ответ в кассету: %w":                     "failed to record the response to the cassette: %w",
+
+	// контекст проекта
+	"Текущее состояние проекта (пути от корня проекта):": "Current project state (paths relative to the project root):",
+	"Файлы:": "Files:",
+	"Объявления из соседних пакетов:":  "Declarations from neighbouring packages:",
+	"\n... (пропущено строк лога: %d)": "\n... (%d log lines omitted)",
+
+	// оркестратор
+	"LM Studio недоступен: %w":                                  "LM Studio is unavailable: %w",
+	"проблема с окружением Go или правами ФС: %w":               "problem with the Go toolchain or file system permissions: %w",
+	"ошибка получения задачи: %w":                               "failed to get task: %w",
+	"не удалось обновить статус run: %w":                        "failed to set status run: %w",
+	"Меняем статус на ok.":                                      "Setting status to ok.",
+	"не удалось обновить статус ok: %w":                         "failed to set status ok: %w",
+	"ошибка получения решения от LM Studio: %w":                 "failed to get a solution from LM Studio: %w",
+	"ошибка выполнения команды: %w":                             "command failed: %w",
+	"ошибка генерации тестов: %w":                               "failed to generate tests: %w",
+	"ошибка выполнения команд тестов: %w":                       "test commands failed: %w",
+	"Оркестратор завершился ошибкой: %v\n":                      "Orchestrator failed: %v\n",
+	"Все задачи обработаны успешно.":                            "All tasks processed successfully.",
+	"Ошибка компиляции.":                                        "Compilation error.",
+	"Ошибка компиляции тестов.":                                 "Test compilation error.",
+	"ошибка компиляции тестов: %w":                              "test compilation failed: %w",
+	"не удалось исправить ошибки компиляции за %d попыток:\n%s": "failed to fix compilation errors in %d attempts:\n%s",
+	"ошибка получения исправления от LM Studio: %w":             "failed to get a fix from LM Studio: %w",
+
+	// команды
+	"неизвестный тип команды: %q":                                     "unknown command type: %q",
+	"файл уже существует: %s":                                         "file already exists: %s",
+	"пустое содержимое для создания файла":                            "empty content for file creation",
+	"не удалось создать директорию: %w":                               "failed to create directory: %w",
+	"файл не существует: %s":                                          "file does not exist: %s",
+	"нет данных для изменения (ни Content, ни Lines)":                 "nothing to change (neither Content nor Lines)",
+	"не удалось прочитать файл %s: %w":                                "failed to read file %s: %w",
+	"некорректный номер строки %q":                                    "invalid line number %q",
+	"строка %d не существует в файле %s":                              "line %d does not exist in file %s",
+	"нет строк для добавления":                                        "no lines to add",
+	"некорректный номер строки для добавления %q: %w":                 "invalid line number to add %q: %w",
+	"нельзя добавить строку %d: файл содержит только %d строк":        "cannot add line %d: the file has only %d lines",
+	"строки для добавления должны идти последовательно без пропусков": "lines to add must be consecutive without gaps",
+	"нет строк для удаления":                                          "no lines to delete",
+	"некорректный номер строки для удаления %q: %w":                   "invalid line number to delete %q: %w",
+	"строка %d не существует в файле %s (всего строк: %d)":            "line %d does not exist in file %s (total lines: %d)",
+	"не указаны пути для копирования":                                 "paths for copying are not specified",
+	"исходный файл не существует: %s":                                 "source file does not exist: %s",
+	"целевой файл уже существует: %s":                                 "destination file already exists: %s",
+	"не удалось прочитать исходный файл: %w":                          "failed to read source file: %w",
+	"не указаны пути для перемещения":                                 "paths for moving are not specified",
+	"путь к файлу задач":                                              "path to the tasks file",
+	"обработать только задачу с этим номером":                         "process only the task with this number",
+	"обрабатывать задачи начиная с этого номера":                      "process tasks starting from this number",
+	"статусы задач через запятую (по умолчанию new)":                  "comma-separated task statuses (default new)",
+	"оркестратор завершился ошибкой: %w":                              "orchestrator failed: %w",
+	"недопустимый статус %q (допустимо: new, run, error, ok)":         "invalid status %q (allowed: new, run, error, ok)",
+	"№\tСТАТУС\tОПИСАНИЕ":                                             "#\tSTATUS\tDESCRIPTION",
+	"Всего: %d (new: %d, run: %d, error: %d, ok: %d)\n":               "Total: %d (new: %d, run: %d, error: %d, ok: %d)\n",
+	"Задача №%d\n":                               "Task #%d\n",
+	"Статус: %s\n":                               "Status: %s\n",
+	"Описание: %s\n":                             "Description: %s\n",
+	"Важные моменты: %s\n":                       "Important notes: %s\n",
+	"Ожидаемый результат: %s\n":                  "Expected result: %s\n",
+	"Тестовые данные: %s\n":                      "Test data: %s\n",
+	"Сигнатура функции: %s\n":                    "Function signature: %s\n",
+	"нужно указать номер задачи":                 "a task number is required",
+	"вернуть в new все задачи со статусом error": "reset all tasks with status error to new",
+	"Задача %d возвращена в статус new.\n":       "Task %d reset to status new.\n",
+	"Нет задач для сброса.":                      "No tasks to reset.",
+	"описание задачи":                            "task description",
+	"важные моменты":                             "important notes",
+	"ожидаемый результат":                        "expected result",
+	"тестовые данные":                            "test data",
+	"сигнатура функции":                          "function signature",
+	"Описание задачи: ":                          "Task description: ",
+	"Важные моменты: ":                           "Important notes: ",
+	"Ожидаемый результат: ":                      "Expected result: ",
+	"Тестовые данные: ":                          "Test data: ",
+	"Сигнатура функции (можно пусто): ":          "Function signature (may be empty): ",
+	"описание задачи не может быть пустым":       "task description must not be empty",
+	"Добавлена задача №%d.\n":                    "Added task #%d.\n",
+	"каталог шаблонов промптов":                  "prompt templates directory",
+	"файл задач %s: %w":                          "tasks file %s: %w",
+	"Файл задач %s в порядке.\n":                 "Tasks file %s is valid.\n",
+	"шаблоны промптов: %w":                       "prompt templates: %w",
+	"Неизвестная команда: %s\n\n":                "Unknown command: %s\n\n",
+	"Ошибка: %v\n":                               "Error: %v\n",
+	"номер задачи %d повторяется":                "task number %d is duplicated",
+	"не удалось открыть файл для записи: %w":     "failed to open file for writing: %w",
+	"ошибка записи в файл задач: %w":             "failed to write the tasks file: %w",
+	"задача № %d не найдена в файле %s":          "task #%d not found in file %s",
+	"задача без номера или с номером %d":         "task without a number or with number %d",
+	"задача № %d: недопустимый статус %q":        "task #%d: invalid status %q",
+	"задача № %d: пустое описание":               "task #%d: empty description",
+	"не удалось прочитать файл настроек: %w":     "failed to read the settings file: %w",
+	"ошибка разбора файла настроек %s: %w":       "failed to parse the settings file %s: %w",
+	"файл настроек %s: %w":                       "settings file %s: %w",
+	"неизвестная настройка %q":                   "unknown setting %q",
+	"настройка %s (переменная %s)":               "setting %s (variable %s)",
+	"неподдерживаемый тип %s":                    "unsupported type %s",
+	"неверное значение %q для %s: %w":            "invalid value %q for %s: %w",
+	"нет, используются значения по умолчанию":    "none, using defaults",
+	`Файл настроек: %s
+`: `Settings file: %s
+`,
+	`Использование: ralf <команда> [флаги]
+
+Команды:
+  run       обработать задачи (--task N, --from N, --only-status new,error)
+  list      таблица задач и их статусов
+  show N    подробности задачи N
+  reset N   вернуть задачу N в статус new (--all-errors — все задачи со статусом error)
+  add       добавить задачу (из флагов или интерактивно)
+  validate  проверить файл задач и шаблоны промптов
+  config    показать действующие настройки
+
+Без команды выполняется run.
+
+Настройки читаются из ralf.yaml или ralf.toml в текущем каталоге, затем
+из переменных окружения RALF_<КЛЮЧ> (например, RALF_MODEL) и флагов
+--<ключ> (например, --model, --output-dir). Список ключей: ralf config.`: `Usage: ralf <command> [flags]
+
+Commands:
+  run       process tasks (--task N, --from N, --only-status new,error)
+  list      table of tasks and their statuses
+  show N    details of task N
+  reset N   reset task N to status new (--all-errors — all tasks with status error)
+  add       add a task (from flags or interactively)
+  validate  check the tasks file and prompt templates
+  config    show the effective settings
+
+Without a command, run is executed.
+
+Settings are read from ralf.yaml or ralf.toml in the current directory, then
+from RALF_<KEY> environment variables (for example, RALF_MODEL) and
+--<key> flags (for example, --model, --output-dir). List of keys: ralf config.`,
+	`
+превышено время ожидания go %s: %s`: `
+go %s timed out after %s`,
+	"неверный каталог проекта %s: %w":                                    "invalid project directory %s: %w",
+	"неверный каталог песочницы %s: %w":                                  "invalid sandbox directory %s: %w",
+	"путь %s выходит за пределы каталога %s":                             "path %s is outside of directory %s",
+	"неизвестный режим git: %q (допустимо: off, commit, branch, merge)":  "unknown git mode: %q (allowed: off, commit, branch, merge)",
+	"каталог проекта не является git-репозиторием с коммитами: %w":       "the project directory is not a git repository with commits: %w",
+	"для работы в ветках нужна текущая ветка (сейчас detached HEAD): %w": "branch modes need a current branch (HEAD is detached): %w",
+	"не удалось создать ветку задачи %s: %w":                             "failed to create task branch %s: %w",
+	"не удалось откатить изменения задачи: %w":                           "failed to roll back task changes: %w",
+	"не удалось закоммитить задачу: %w":                                  "failed to commit the task: %w",
+	"не удалось влить ветку %s: %w":                                      "failed to merge branch %s: %w",
+	"Задача %d: %s": "Task %d: %s",
+	"не удалось скопировать проект: %w":                          "failed to copy the project: %w",
+	"не удалось обновить статус %s: %w":                          "failed to update status %s: %w",
+	"конфликт: файлы %s уже изменены другой задачей или вручную": "conflict: files %s were already changed by another task or manually",
+	`конфликт: после вливания задачи проект не собирается:
+%s`: `conflict: the project does not build after merging the task:
+%s`,
+	"не удалось создать каталог отчётов: %w": "failed to create the reports directory: %w",
+	"не удалось записать отчёт: %w":          "failed to write the report: %w",
+	`# Отчёт Ralf от %s
+
+`: `# Ralf report of %s
+
+`,
+	"Модель: `%s`, задач: %d, вызовов LLM: %d, токенов: %d запрос / %d ответ.\n\n": "Model: `%s`, tasks: %d, LLM calls: %d, tokens: %d prompt / %d completion.\n\n",
+	"## Этапы": "## Stages",
+	"| Этап | Дошли | Прошли | Доля |": "| Stage | Reached | Passed | Rate |",
+	"## Задачи": "## Tasks",
+	"| № | Статус | Попытки (%s) | Попытки задачи | Время, с | LLM | Токены | Сборка | Тесты | Покрытие | Модель | Файлы |": "| # | Status | Attempts (%s) | Task attempts | Time, s | LLM | Tokens | Build | Tests | Coverage | Model | Files |",
+	"## Ошибки":                                                             "## Errors",
+	"\n### Задача %d\n\n```\n%s\n```\n":                                     "\n### Task %d\n\n```\n%s\n```\n",
+	"Ветка задачи оставлена для ревью":                                      "Task branch is left for review",
+	"Взяли задачу в работу":                                                 "Task taken into work",
+	"Все задачи обработаны":                                                 "All tasks processed",
+	"Выполняем команду модели":                                              "Executing model command",
+	"Задача влита в проект":                                                 "Task merged into the project",
+	"Задача выполнена":                                                      "Task done",
+	"Задача завершилась ошибкой":                                            "Task failed",
+	"Запрос превышает бюджет контекста":                                     "Request exceeds the context budget",
+	"Изменения задачи закоммичены":                                          "Task changes committed",
+	"Изменения задачи откачены":                                             "Task changes rolled back",
+	"Исправление не применилось":                                            "Fix was not applied",
+	"Начинаем цикл обработки задач":                                         "Starting the task loop",
+	"Не удалось разобрать исправления":                                      "Failed to parse the fixes",
+	"Отправляем модели ошибки сборки":                                       "Sending build errors to the model",
+	"Отчёт о запуске записан":                                               "Run report written",
+	"Параллельная обработка задач":                                          "Processing tasks in parallel",
+	"Получен ответ модели":                                                  "Model response received",
+	"Проверяем доступ к LM Studio, Go и каталогу проекта":                   "Checking access to LM Studio, Go and the project directory",
+	"Разобраны команды модели":                                              "Model commands parsed",
+	"не удалось открыть журнал промптов: %w":                                "failed to open the prompt log: %w",
+	"неизвестный уровень журнала: %q (допустимо: debug, info, warn, error)": "unknown log level: %q (allowed: debug, info, warn, error)",
+	"неизвестный формат журнала: %q (допустимо: text, json)":                "unknown log format: %q (allowed: text, json)",
+	"go %s прерван: %w":                                                     "go %s interrupted: %w",
+	"Обработка прервана, задача возвращена в статус new":                    "Processing interrupted, the task is back to new",
+	"не удалось обновить статус new: %w":                                    "failed to update status to new: %w",
+	"обработка прервана: %w":                                                "processing interrupted: %w",
+	"Обработка прервана, невлитые задачи возвращены в статус new":           "Processing interrupted, unmerged tasks are back to new",
+	"%w: израсходовано %d токенов при лимите %d на %s":                      "%w: used %d tokens with a limit of %d per %s",
+	"%w: истекло время на %s (%s)":                                          "%w: time is up for %s (%s)",
+	"Бюджет запуска исчерпан, оставшиеся задачи не начаты":                  "Run budget exhausted, remaining tasks are not started",
+	"бюджет исчерпан":                                                       "budget exhausted",
+	"задачу":                                                                "task",
+	"запуск":                                                                "run",
+	"этап %s":                                                               "stage %s",
+	"задача не решена за %d попыток: %w":                                    "task not solved in %d attempts: %w",
+	"Попытка задачи не удалась, начинаем заново":                            "Task attempt failed, starting over",
+	"Переходим к следующей модели цепочки":                                  "Escalating to the next model in the chain",
+	"Вариант решения отброшен":                                              "Solution candidate discarded",
+	"Оценён вариант решения":                                                "Solution candidate scored",
+	"Выбран вариант решения":                                                "Solution candidate selected",
+	"ни один из %d вариантов решения не подошёл: %w":                        "none of the %d solution candidates is usable: %w",
+	"неверный формат номера родительской задачи: %w":                        "invalid parent task number format: %w",
+	"задача № %d: нет родительской задачи № %d":                             "task #%d: parent task #%d does not exist",
+	"Выполняем подзадачу":                                                   "Running subtask",
+	"подзадача %d не выполнена: %w":                                         "subtask %d failed: %w",
+	"ошибка планирования задачи: %w":                                        "task planning failed: %w",
+	"Модель не стала разбивать задачу на подзадачи":                         "The model did not split the task into subtasks",
+	"не удалось записать подзадачу: %w":                                     "failed to write subtask: %w",
+	"Подзадача добавлена в план":                                            "Subtask added to the plan",
+	"ошибка ревью решения: %w":                                              "solution review error: %w",
+	"Ревью: рискованное место":                                              "Review: risky spot",
+	"Ревью: замечание по стилю":                                             "Review: style remark",
+	"ревью: требования не выполнены после %d доработок: %s":                 "review: requirements still unmet after %d fixes: %s",
+	"Ревью нашло невыполненные требования, дорабатываем":                    "Review found unmet requirements, fixing",
+	"ошибка получения доработки от LM Studio: %w":                           "error getting the review fix from LM Studio: %w",
+	"найдены замечания анализаторов":                                        "analyzers reported findings",
+	"проверка %s: неизвестная политика %q (нужно fix или report)":           "check %s: unknown policy %q (expected fix or report)",
+	"Замечание анализатора":                                                 "Analyzer finding",
+	"Анализатор не установлен, проверка пропущена":                          "Analyzer is not installed, check skipped",
+	"файл не отформатирован gofmt":                                          "file is not gofmt-formatted",
+	"%s прерван: %w":                                                        "%s interrupted: %w",
+	"не удалось запустить %s: %w":                                           "failed to run %s: %w",
+	`
+превышено время ожидания %s: %s`: `
+%s timed out: %s`,
+	"## Замечания анализаторов": "## Analyzer findings",
+	`
+### Задача %d
+
+`: `
+### Task %d
+
+`,
+	"замечания анализаторов не исправлены: %w":       "analyzer findings not fixed: %w",
+	"Файл не разбирается, форматирование пропущено":  "File does not parse, formatting skipped",
+	"Не удалось отформатировать файл":                "Failed to format the file",
+	"синтаксическая ошибка в коде модели":            "syntax error in the model's code",
+	"синтаксическая ошибка, файл не записан:":        "syntax error, the file was not written:",
+	"Код модели не разбирается, файл не записан":     "Model code does not parse, the file was not written",
+	"Покрытие тестами":                               "Test coverage",
+	"покрытие тестами %.1f%% ниже требуемого %.1f%%": "test coverage %.1f%% is below the required %.1f%%",
+	"Покрытие ниже порога, просим дополнить тесты":   "Coverage is below the threshold, asking for more tests",
+	"ошибка получения тестов от LM Studio: %w":       "error getting tests from LM Studio: %w",
+	"Тесты не прошли при измерении покрытия":         "Tests failed while measuring coverage",
+	`не удалось получить профиль покрытия: %w
+%s`: `failed to get the coverage profile: %w
+%s`,
+	"не удалось получить список пакетов: %w":                                   "failed to list packages: %w",
+	"неверная строка профиля покрытия: %q":                                     "invalid coverage profile line: %q",
+	"Конфликт при вливании, задача выполняется заново поверх влитых изменений": "Merge conflict, rerunning the task on top of the merged changes",
+	"синтаксическая ошибка в записанном файле:":                                "syntax error in the written file:",
+	`тесты не прошли при измерении покрытия:
+%s`: `tests failed while measuring coverage:
+%s`,
+	"неверный формат числа попыток: %w":                                             "invalid number of attempts: %w",
+	"не получилось записать число попыток задачи № %d в файл %s: задача не найдена": "failed to record the attempts of task #%d in file %s: task not found",
+	"не удалось записать число попыток задачи: %w":                                  "failed to record the task attempts: %w",
+	`Попыток: %d
+`: `Attempts: %d
+`,
+	"Решение не собралось, задача решается заново следующей моделью цепочки": "The solution did not build, solving the task again with the next model of the chain",
+	"Не удалось получить общие тесты для оценки вариантов решения":           "Failed to get shared tests for scoring the solution candidates",
+	"Тесты не проходят, просим их исправить":                                 "Tests fail, asking the model to fix them",
+}
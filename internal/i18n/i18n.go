@@ -0,0 +1,72 @@
+// Package i18n переводит сообщения консоли и ошибок на выбранный язык.
+// Ключом сообщения служит его русский текст: в коде остаются читаемые
+// строки, а для английского режима перевод берётся из каталога english.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Lang — язык промптов, типов команд и сообщений.
+type Lang string
+
+const (
+	Russian Lang = "ru"
+	English Lang = "en"
+)
+
+// current — текущий язык; по умолчанию русский.
+var current atomic.Value
+
+func init() {
+	current.Store(Russian)
+}
+
+// ParseLang разбирает название языка ("ru", "en", "russian", "english").
+// Пустая строка означает русский язык.
+func ParseLang(s string) (Lang, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "ru", "rus", "russian", "русский":
+		return Russian, nil
+	case "en", "eng", "english", "английский":
+		return English, nil
+	default:
+		return "", fmt.Errorf("неизвестный язык: %q (допустимо: ru, en)", s)
+	}
+}
+
+// SetLanguage устанавливает текущий язык.
+func SetLanguage(lang Lang) {
+	current.Store(lang)
+}
+
+// Current возвращает текущий язык.
+func Current() Lang {
+	return current.Load().(Lang)
+}
+
+// T возвращает перевод сообщения на текущий язык.
+// Если перевода нет, возвращается исходный русский текст.
+func T(msg string) string {
+	if Current() == English {
+		if translated, ok := english[msg]; ok {
+			return translated
+		}
+	}
+	return msg
+}
+
+// localizedError — ошибка, текст которой переводится в момент вывода.
+type localizedError string
+
+func (e localizedError) Error() string {
+	return T(string(e))
+}
+
+// Error создаёт ошибку с переводимым текстом. Подходит для ошибок-сигналов
+// уровня пакета, которые создаются до выбора языка.
+func Error(msg string) error {
+	return localizedError(msg)
+}
@@ -0,0 +1,114 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestEnglishCatalogComplete проверяет, что у каждого сообщения, переданного
+// в i18n.T или i18n.Error в коде модуля, есть английский перевод.
+func TestEnglishCatalogComplete(t *testing.T) {
+	root := filepath.Join("..", "..")
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && path != root {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 1 || !isTranslateCall(call.Fun) {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			msg, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Errorf("%s: %v", fset.Position(lit.Pos()), err)
+				return true
+			}
+			if _, ok := english[msg]; !ok {
+				t.Errorf("%s: нет английского перевода для %q", fset.Position(lit.Pos()), msg)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// isTranslateCall сообщает, что вызывается T или Error (в том числе как i18n.T).
+func isTranslateCall(fun ast.Expr) bool {
+	switch f := fun.(type) {
+	case *ast.SelectorExpr:
+		pkg, ok := f.X.(*ast.Ident)
+		return ok && pkg.Name == "i18n" && (f.Sel.Name == "T" || f.Sel.Name == "Error")
+	case *ast.Ident:
+		return f.Name == "T"
+	}
+	return false
+}
+
+func TestT(t *testing.T) {
+	defer SetLanguage(Current())
+
+	SetLanguage(Russian)
+	if got := T("Меняем статус на ok."); got != "Меняем статус на ok." {
+		t.Errorf("T() in ru = %q", got)
+	}
+	SetLanguage(English)
+	if got := T("Меняем статус на ok."); got != "Setting status to ok." {
+		t.Errorf("T() in en = %q", got)
+	}
+	if got := T("нет такого сообщения"); got != "нет такого сообщения" {
+		t.Errorf("T() without translation = %q", got)
+	}
+	if got := Error("не найдено задач со статусом new").Error(); got != "no tasks with status new found" {
+		t.Errorf("Error() in en = %q", got)
+	}
+}
+
+func TestParseLang(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Lang
+		wantErr bool
+	}{
+		{in: "", want: Russian},
+		{in: "ru", want: Russian},
+		{in: "EN", want: English},
+		{in: "english", want: English},
+		{in: "de", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLang(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLang() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseLang() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
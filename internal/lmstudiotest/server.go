@@ -0,0 +1,232 @@
+// Package lmstudiotest предоставляет поддельный LM Studio для тестов:
+// httptest-сервер с OpenAI-совместимыми /v1/models и /v1/chat/completions,
+// ответы которого задаются сценарием.
+//
+//	srv := lmstudiotest.NewServer(t).
+//		ReplyCommands(cmds).        // массив команд
+//		Reply("это не JSON").       // некорректный ответ модели
+//		ReplyStatus(500, "упал")    // ошибка сервера
+//	cfg.Endpoint = srv.Endpoint()
+//
+// Когда запросы идут параллельно, ответ можно привязать к тексту запроса:
+// ReplyCommandsFor("func Add(", cmds) достанется только запросу, в сообщениях
+// которого есть эта строка.
+package lmstudiotest
+
+import (
+	"Ralf/domen"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Message — сообщение чата в запросе.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request — принятый сервером запрос /chat/completions.
+type Request struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+	MaxTokens   int       `json:"max_tokens"`
+}
+
+// reply — один шаг сценария.
+type reply struct {
+	match   string // ответ только для запросов, содержащих эту строку (пусто — для любого)
+	status  int
+	content string // текст ответа модели (если body пуст)
+	body    string // тело ответа как есть
+	hang    func() // ответа нет: вызвать hang и ждать, пока клиент не отменит запрос
+}
+
+// Server — поддельный LM Studio.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	models   []string
+	script   []reply
+	requests []Request
+}
+
+// NewServer запускает сервер и останавливает его по завершении теста.
+func NewServer(t testing.TB) *Server {
+	s := &Server{models: []string{"local-model"}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChat)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Endpoint возвращает базовый адрес API для domen.Config.Endpoint.
+func (s *Server) Endpoint() string {
+	return s.URL + "/v1"
+}
+
+// Models задаёт список моделей, который возвращает /v1/models.
+func (s *Server) Models(models ...string) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models = models
+	return s
+}
+
+// Reply добавляет в сценарий успешный ответ модели с текстом content.
+func (s *Server) Reply(content string) *Server {
+	return s.push(reply{status: http.StatusOK, content: content})
+}
+
+// ReplyCommands добавляет в сценарий ответ модели с JSON-массивом команд.
+func (s *Server) ReplyCommands(commands ...domen.Command) *Server {
+	data, err := json.Marshal(commands)
+	if err != nil {
+		panic(err)
+	}
+	return s.Reply(string(data))
+}
+
+// ReplyCommandsFor добавляет в сценарий ответ с командами, который получит
+// первый запрос, содержащий в сообщениях строку match.
+func (s *Server) ReplyCommandsFor(match string, commands ...domen.Command) *Server {
+	data, err := json.Marshal(commands)
+	if err != nil {
+		panic(err)
+	}
+	return s.push(reply{match: match, status: http.StatusOK, content: string(data)})
+}
+
+// ReplyRaw добавляет в сценарий ответ с кодом 200 и произвольным телом,
+// например некорректным JSON вместо chat completion.
+func (s *Server) ReplyRaw(body string) *Server {
+	return s.push(reply{status: http.StatusOK, body: body})
+}
+
+// ReplyStatus добавляет в сценарий ответ с кодом ошибки.
+func (s *Server) ReplyStatus(status int, body string) *Server {
+	return s.push(reply{status: status, body: body})
+}
+
+// ReplyHang добавляет в сценарий запрос, который «зависает»: сервер вызывает
+// onRequest и не отвечает, пока клиент не отменит запрос. Так проверяется
+// прерывание долгого запроса к модели.
+func (s *Server) ReplyHang(onRequest func()) *Server {
+	return s.push(reply{status: http.StatusOK, hang: onRequest})
+}
+
+// Requests возвращает принятые запросы /chat/completions по порядку.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Pending возвращает количество неиспользованных шагов сценария.
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.script)
+}
+
+func (s *Server) push(r reply) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, r)
+	return s
+}
+
+// nextReply возвращает номер первого шага сценария, подходящего к запросу, или -1.
+func (s *Server) nextReply(req Request) int {
+	for i, r := range s.script {
+		if r.match == "" {
+			return i
+		}
+		for _, m := range req.Messages {
+			if strings.Contains(m.Content, r.match) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	models := append([]string(nil), s.models...)
+	s.mu.Unlock()
+
+	type model struct {
+		ID     string `json:"id"`
+		Object string `json:"object"`
+	}
+	resp := struct {
+		Object string  `json:"object"`
+		Data   []model `json:"data"`
+	}{Object: "list"}
+	for _, id := range models {
+		resp.Data = append(resp.Data, model{ID: id, Object: "model"})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("некорректный запрос: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	if len(s.script) == 0 {
+		s.mu.Unlock()
+		http.Error(w, "сценарий ответов исчерпан", http.StatusInternalServerError)
+		return
+	}
+	i := s.nextReply(req)
+	if i < 0 {
+		s.mu.Unlock()
+		http.Error(w, "в сценарии нет ответа для запроса", http.StatusInternalServerError)
+		return
+	}
+	next := s.script[i]
+	s.script = append(s.script[:i], s.script[i+1:]...)
+	s.mu.Unlock()
+
+	if next.hang != nil {
+		next.hang()
+		<-r.Context().Done()
+		return
+	}
+	if next.body != "" || next.status != http.StatusOK {
+		w.WriteHeader(next.status)
+		_, _ = w.Write([]byte(next.body))
+		return
+	}
+
+	content := next.content
+	prompt := 0
+	for _, m := range req.Messages {
+		prompt += len(m.Content) / 4
+	}
+	completion := len(content) / 4
+	resp := map[string]any{
+		"id":      fmt.Sprintf("chatcmpl-%d", len(s.Requests())),
+		"object":  "chat.completion",
+		"model":   req.Model,
+		"choices": []map[string]any{{"index": 0, "message": Message{Role: "assistant", Content: content}, "finish_reason": "stop"}},
+		"usage":   map[string]int{"prompt_tokens": prompt, "completion_tokens": completion, "total_tokens": prompt + completion},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,79 @@
+// Package logging настраивает структурированный журнал на log/slog: основной
+// журнал с уровнем и форматом из настроек и отдельный отладочный журнал
+// с полными промптами и ответами модели.
+package logging
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Ключи атрибутов, общие для всех записей журнала.
+const (
+	KeyTask    = "task"    // номер задачи
+	KeyStage   = "stage"   // этап обработки задачи (см. service.Stages)
+	KeyAttempt = "attempt" // номер попытки на этапе
+	KeyCommand = "command" // тип команды модели
+	KeyPath    = "path"    // путь к файлу
+	KeyError   = "error"   // текст ошибки
+)
+
+// Форматы основного журнала (domen.Config.LogFormat).
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var prompts atomic.Pointer[slog.Logger]
+
+func init() {
+	prompts.Store(slog.New(slog.DiscardHandler))
+}
+
+// Prompts возвращает журнал промптов и ответов модели. Пока он не настроен
+// через Setup, записи отбрасываются.
+func Prompts() *slog.Logger {
+	return prompts.Load()
+}
+
+// Setup настраивает журналы по cfg: основной пишется в w и становится
+// slog.Default, журнал промптов пишется в файл cfg.PromptLog в формате JSON
+// (если путь задан). Возвращает функцию, закрывающую файл журнала промптов.
+func Setup(cfg domen.Config, w io.Writer) (func() error, error) {
+	cfg = cfg.WithDefaults()
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return nil, fmt.Errorf(i18n.T("неизвестный уровень журнала: %q (допустимо: debug, info, warn, error)"), cfg.LogLevel)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.LogFormat) {
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf(i18n.T("неизвестный формат журнала: %q (допустимо: text, json)"), cfg.LogFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+
+	if cfg.PromptLog == "" {
+		prompts.Store(slog.New(slog.DiscardHandler))
+		return func() error { return nil }, nil
+	}
+	f, err := os.OpenFile(cfg.PromptLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.T("не удалось открыть журнал промптов: %w"), err)
+	}
+	prompts.Store(slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	return func() error {
+		prompts.Store(slog.New(slog.DiscardHandler))
+		return f.Close()
+	}, nil
+}
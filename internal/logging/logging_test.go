@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"Ralf/domen"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     domen.Config
+		want    string // фрагмент вывода после Info и Debug
+		notWant string
+		wantErr bool
+	}{
+		{name: "text info", cfg: domen.Config{}, want: `level=INFO msg=hello task=3`, notWant: "debug message"},
+		{name: "json debug", cfg: domen.Config{LogFormat: "json", LogLevel: "debug"}, want: `"msg":"debug message","task":3`},
+		{name: "warn hides info", cfg: domen.Config{LogLevel: "warn"}, notWant: "hello"},
+		{name: "unknown level", cfg: domen.Config{LogLevel: "verbose"}, wantErr: true},
+		{name: "unknown format", cfg: domen.Config{LogFormat: "xml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := slog.Default()
+			t.Cleanup(func() { slog.SetDefault(prev) })
+
+			var out bytes.Buffer
+			closeLog, err := Setup(tt.cfg, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer closeLog()
+			slog.Info("hello", KeyTask, 3)
+			slog.Debug("debug message", KeyTask, 3)
+			if tt.want != "" && !strings.Contains(out.String(), tt.want) {
+				t.Errorf("log = %q, want containing %q", out.String(), tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(out.String(), tt.notWant) {
+				t.Errorf("log = %q, want without %q", out.String(), tt.notWant)
+			}
+		})
+	}
+}
+
+func TestSetup_promptLog(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	path := filepath.Join(t.TempDir(), "prompts.jsonl")
+
+	var out bytes.Buffer
+	closeLog, err := Setup(domen.Config{PromptLog: path}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Prompts().Debug("chat", KeyTask, 1, "response", "[]")
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+	Prompts().Debug("chat", KeyTask, 2) // после закрытия записи отбрасываются
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("prompt log is not a single JSON record: %v\n%s", err, data)
+	}
+	if record["level"] != "DEBUG" || record["response"] != "[]" || record[KeyTask] != float64(1) {
+		t.Errorf("prompt record = %v", record)
+	}
+	if out.Len() != 0 {
+		t.Errorf("prompts leaked into the main log: %q", out.String())
+	}
+}
@@ -0,0 +1,236 @@
+// Package prompts загружает шаблоны промптов для LM Studio.
+// Встроенные шаблоны лежат в templates/<язык>/ и вшиваются в бинарник через go:embed;
+// любой из них можно переопределить файлом <язык>/<имя>.tmpl или <имя>.tmpl
+// в каталоге промптов.
+package prompts
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/ru/*.tmpl templates/en/*.tmpl
+var defaults embed.FS
+
+// Имена шаблонов (имя файла без расширения .tmpl).
+const (
+	System     = "system"      // системный промпт с форматом команд
+	Task       = "task"        // запрос на решение задачи
+	Tests      = "tests"       // запрос на генерацию тестов
+	CompileFix = "compile_fix" // запрос на исправление ошибки компиляции
+	Plan       = "plan"        // запрос на разбиение задачи на подзадачи
+	Review     = "review"      // запрос на ревью решения
+	ReviewFix  = "review_fix"  // запрос на доработку по итогам ревью
+	Coverage   = "coverage"    // запрос на дополнение тестов по непокрытым строкам
+)
+
+// Names — все шаблоны, которые должны быть доступны.
+var Names = []string{System, Task, Tests, CompileFix, Plan, Review, ReviewFix, Coverage}
+
+// Languages — языки, для которых есть встроенные шаблоны.
+var Languages = []i18n.Lang{i18n.Russian, i18n.English}
+
+// SystemData — данные системного промпта.
+type SystemData struct {
+	OutputDir    string   // каталог, внутри которого создаются все файлы
+	CommandTypes []string // допустимые значения поля Type
+}
+
+// TaskData — данные запроса на решение задачи.
+type TaskData struct {
+	Task        domen.Task
+	OutputDir   string
+	RepoContext string // описание существующего проекта, может быть пустым
+	PrevFailure string // причина неудачи предыдущей попытки задачи, может быть пустой
+}
+
+// TestsData — данные запроса на генерацию тестов.
+type TestsData struct {
+	Task       domen.Task
+	TestFile   string // путь к файлу тестов
+	CreateType string // значение Type для команды создания файла
+}
+
+// CompileFixData — данные запроса на исправление ошибки компиляции.
+type CompileFixData struct {
+	Attempt int
+	Path    string
+	Code    string
+	Log     string
+}
+
+// PlanData — данные запроса на разбиение задачи на подзадачи.
+type PlanData struct {
+	Task        domen.Task
+	MaxSubtasks int // наибольшее допустимое число подзадач
+}
+
+// ReviewData — данные запроса на ревью решения.
+type ReviewData struct {
+	Task domen.Task
+	Diff string // изменения, внесённые решением
+}
+
+// ReviewFixData — данные запроса на доработку по итогам ревью.
+type ReviewFixData struct {
+	Task  domen.Task
+	Round int      // номер раунда доработки
+	Unmet []string // невыполненные требования с пояснениями ревьюера
+}
+
+// CoverageData — данные запроса на дополнение тестов.
+type CoverageData struct {
+	Task      domen.Task
+	Round     int      // номер раунда дополнения
+	Coverage  float64  // текущее покрытие, %
+	Threshold float64  // требуемое покрытие, %
+	Uncovered []string // непокрытые участки: место и код
+	Failures  string   // вывод упавших тестов (пусто — тесты прошли)
+	TestFile  string
+	TestCode  string
+}
+
+// Set — набор загруженных шаблонов.
+type Set struct {
+	templates map[string]*template.Template
+}
+
+// Load загружает шаблоны языка lang: файл из dir, если он есть, иначе встроенный
+// по умолчанию. Пустой dir означает только встроенные шаблоны.
+func Load(dir string, lang i18n.Lang) (*Set, error) {
+	s := &Set{templates: make(map[string]*template.Template)}
+	for _, name := range Names {
+		text, err := source(dir, lang, name)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf(i18n.T("ошибка разбора шаблона %s: %w"), name, err)
+		}
+		s.templates[name] = tmpl
+	}
+	return s, nil
+}
+
+// source возвращает текст шаблона name: из dir/<lang>/, затем из dir/, затем встроенный.
+func source(dir string, lang i18n.Lang, name string) (string, error) {
+	if dir != "" {
+		for _, path := range []string{
+			filepath.Join(dir, string(lang), name+".tmpl"),
+			filepath.Join(dir, name+".tmpl"),
+		} {
+			data, err := os.ReadFile(path)
+			if err == nil {
+				return string(data), nil
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				return "", fmt.Errorf(i18n.T("не удалось прочитать шаблон %s: %w"), name, err)
+			}
+		}
+	}
+	data, err := defaults.ReadFile("templates/" + string(lang) + "/" + name + ".tmpl")
+	if err != nil {
+		return "", fmt.Errorf(i18n.T("нет встроенного шаблона %s для языка %s: %w"), name, lang, err)
+	}
+	return string(data), nil
+}
+
+// System формирует системный промпт.
+func (s *Set) System(data SystemData) (string, error) {
+	return s.render(System, data)
+}
+
+// Task формирует запрос на решение задачи.
+func (s *Set) Task(data TaskData) (string, error) {
+	return s.render(Task, data)
+}
+
+// Tests формирует запрос на генерацию тестов.
+func (s *Set) Tests(data TestsData) (string, error) {
+	return s.render(Tests, data)
+}
+
+// CompileFix формирует запрос на исправление ошибки компиляции.
+func (s *Set) CompileFix(data CompileFixData) (string, error) {
+	return s.render(CompileFix, data)
+}
+
+// Plan формирует запрос на разбиение задачи на подзадачи.
+func (s *Set) Plan(data PlanData) (string, error) {
+	return s.render(Plan, data)
+}
+
+// Review формирует запрос на ревью решения.
+func (s *Set) Review(data ReviewData) (string, error) {
+	return s.render(Review, data)
+}
+
+// ReviewFix формирует запрос на доработку по итогам ревью.
+func (s *Set) ReviewFix(data ReviewFixData) (string, error) {
+	return s.render(ReviewFix, data)
+}
+
+// Coverage формирует запрос на дополнение тестов по непокрытым строкам.
+func (s *Set) Coverage(data CoverageData) (string, error) {
+	return s.render(Coverage, data)
+}
+
+func (s *Set) render(name string, data any) (string, error) {
+	var sb strings.Builder
+	if err := s.templates[name].Execute(&sb, data); err != nil {
+		return "", fmt.Errorf(i18n.T("ошибка шаблона %s: %w"), name, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// Validate загружает шаблоны из dir для всех языков и проверяет, что каждый
+// из них выполняется без ошибок на тестовых данных.
+func Validate(dir string) error {
+	var errs []error
+	for _, lang := range Languages {
+		if err := validateLang(dir, lang); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", lang, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateLang(dir string, lang i18n.Lang) error {
+	s, err := Load(dir, lang)
+	if err != nil {
+		return err
+	}
+	task := domen.Task{
+		Num:           1,
+		Description:   "описание",
+		ImportantInfo: "важные моменты",
+		ExpectResult:  "ожидаемый результат",
+		TestsValue:    "тестовые данные",
+		FuncSignature: "func Greeting(name string) string",
+		Status:        domen.StatusNew,
+	}
+	var errs []error
+	check := func(_ string, err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	check(s.System(SystemData{OutputDir: "prog", CommandTypes: []string{string(domen.CmdCreate)}}))
+	check(s.Task(TaskData{Task: task, OutputDir: "prog", RepoContext: "контекст", PrevFailure: "ошибка"}))
+	check(s.Tests(TestsData{Task: task, TestFile: "prog/greeting_test.go", CreateType: string(domen.CmdCreate)}))
+	check(s.CompileFix(CompileFixData{Attempt: 1, Path: "prog/main.go", Code: "package main", Log: "ошибка"}))
+	check(s.Plan(PlanData{Task: task, MaxSubtasks: 5}))
+	check(s.Review(ReviewData{Task: task, Diff: "+package main"}))
+	check(s.ReviewFix(ReviewFixData{Task: task, Round: 1, Unmet: []string{"требование: пояснение"}}))
+	check(s.Coverage(CoverageData{Task: task, Round: 1, Coverage: 40, Threshold: 80, Uncovered: []string{"prog/main.go:4-5"},
+		Failures: "--- FAIL: TestGreeting", TestFile: "prog/greeting_test.go", TestCode: "package main"}))
+	return errors.Join(errs...)
+}
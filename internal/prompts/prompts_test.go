@@ -0,0 +1,88 @@
+package prompts
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		wantErr   bool
+	}{
+		{
+			name:    "defaults",
+			wantErr: false,
+		},
+		{
+			name:      "valid override",
+			overrides: map[string]string{"task.tmpl": "Task {{.Task.Num}} in {{.OutputDir}}"},
+			wantErr:   false,
+		},
+		{
+			name:      "parse error",
+			overrides: map[string]string{"system.tmpl": "{{range .CommandTypes}}"},
+			wantErr:   true,
+		},
+		{
+			name:      "language override",
+			overrides: map[string]string{"en/tests.tmpl": "{{.Missing}}"},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown field",
+			overrides: map[string]string{"compile_fix.tmpl": "{{.Task.Num}}"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.overrides {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := Validate(dir); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSet_Task(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "task.tmpl"), []byte("Задача {{.Task.Num}}: {{.Task.Description}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(dir, i18n.Russian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Task(TaskData{Task: domen.Task{Num: 7, Description: "описание"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Задача 7: описание" {
+		t.Errorf("Task() = %q", got)
+	}
+
+	// Остальные шаблоны берутся встроенными
+	system, err := s.System(SystemData{OutputDir: "prog", CommandTypes: []string{"создание", "удаление"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`- "создание"`, `- "удаление"`, "prog/main.go"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("System() missing %q", want)
+		}
+	}
+}
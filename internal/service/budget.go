@@ -0,0 +1,76 @@
+package service
+
+import (
+	"Ralf/internal/i18n"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded — задача остановлена, потому что исчерпан лимит времени или токенов.
+var ErrBudgetExceeded = i18n.Error("бюджет исчерпан")
+
+// TokenBudget — лимит токенов LLM (запрос + ответ) на задачу или на весь запуск.
+// Расход задачи учитывается и в бюджете запуска (parent). Методы безопасны
+// для nil и для одновременного использования из нескольких задач.
+type TokenBudget struct {
+	mu     sync.Mutex
+	scope  string // «задачу» или «запуск» — для сообщения об ошибке
+	limit  int    // 0 — без ограничения
+	used   int
+	parent *TokenBudget
+}
+
+// NewTokenBudget создаёт бюджет с лимитом limit токенов (0 — без ограничения).
+func NewTokenBudget(scope string, limit int, parent *TokenBudget) *TokenBudget {
+	return &TokenBudget{scope: scope, limit: limit, parent: parent}
+}
+
+// spend учитывает израсходованные токены.
+func (b *TokenBudget) spend(tokens int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.used += tokens
+	b.mu.Unlock()
+	b.parent.spend(tokens)
+}
+
+// check возвращает ошибку, если бюджет (свой или родительский) уже исчерпан.
+// Вызывается перед запросом к модели: начатый запрос доводится до конца.
+func (b *TokenBudget) check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	used, limit := b.used, b.limit
+	b.mu.Unlock()
+	if limit > 0 && used >= limit {
+		return fmt.Errorf(i18n.T("%w: израсходовано %d токенов при лимите %d на %s"), ErrBudgetExceeded, used, limit, b.scope)
+	}
+	return b.parent.check()
+}
+
+// withTimeout ограничивает ctx временем d; по истечении context.Cause вернёт
+// ошибку ErrBudgetExceeded с описанием what. Нулевое d — без ограничения.
+func withTimeout(ctx context.Context, d time.Duration, what string) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, d, fmt.Errorf(i18n.T("%w: истекло время на %s (%s)"), ErrBudgetExceeded, what, d))
+}
+
+// budgetError заменяет ошибку этапа причиной остановки, если ctx остановлен
+// по лимиту времени: вместо «context deadline exceeded» задача получает понятную причину.
+func budgetError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrBudgetExceeded) {
+		return cause
+	}
+	return err
+}
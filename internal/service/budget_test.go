@@ -0,0 +1,85 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBudget(t *testing.T) {
+	run := NewTokenBudget("запуск", 100, nil)
+	task := NewTokenBudget("задачу", 50, run)
+
+	task.spend(40)
+	if err := task.check(); err != nil {
+		t.Fatalf("check() under the limit = %v", err)
+	}
+	task.spend(10)
+	if err := task.check(); !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), "50 на задачу") {
+		t.Errorf("task check() = %v, want task budget exceeded", err)
+	}
+
+	// расход задач копится в бюджете запуска
+	other := NewTokenBudget("задачу", 0, run)
+	other.spend(50)
+	if err := other.check(); !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), "100 на запуск") {
+		t.Errorf("run check() = %v, want run budget exceeded", err)
+	}
+	if err := (*TokenBudget)(nil).check(); err != nil {
+		t.Errorf("nil check() = %v", err)
+	}
+}
+
+func Test_runTask_budget(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     domen.Config
+		script  func(s *lmstudiotest.Server)
+		wantErr string
+	}{
+		{
+			name: "task tokens",
+			cfg:  domen.Config{MaxTaskTokens: 10},
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyCommands(createBadMain)
+			},
+			wantErr: "при лимите 10 на задачу",
+		},
+		{
+			name: "stage timeout",
+			cfg:  domen.Config{StageTimeout: 100 * time.Millisecond},
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyHang(func() {})
+			},
+			wantErr: "истекло время на этап solve",
+		},
+		{
+			name: "task timeout",
+			cfg:  domen.Config{TaskTimeout: 100 * time.Millisecond},
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyHang(func() {})
+			},
+			wantErr: "истекло время на задачу",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSandbox(t)
+			srv := lmstudiotest.NewServer(t)
+			tt.script(srv)
+			cfg := tt.cfg
+			cfg.Endpoint = srv.Endpoint()
+
+			err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), nil, nil)
+			if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("runTask() error = %v, want budget error containing %q", err, tt.wantErr)
+			}
+			if srv.Pending() != 0 {
+				t.Errorf("runTask() left %d scripted replies unused", srv.Pending())
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// candidateTemperatureStep — разница температур соседних кандидатов решения:
+// первый запрашивается с обычной температурой, остальные — всё смелее.
+const candidateTemperatureStep = 0.3
+
+// candidate — один из вариантов решения задачи и его оценка.
+type candidate struct {
+	output   string          // ответ модели в исходном виде
+	commands []domen.Command // разобранные команды
+	err      error           // ошибка получения, разбора или применения команд
+	compiled bool            // проект собирается вместе с тестами
+	passed   int             // число прошедших тестов
+	changed  int             // число изменённых строк
+}
+
+// better сообщает, что кандидат c лучше o: собирается, проходит больше тестов,
+// а при равенстве — меняет меньше строк.
+func (c *candidate) better(o *candidate) bool {
+	if (c.err == nil) != (o.err == nil) {
+		return c.err == nil
+	}
+	if c.compiled != o.compiled {
+		return c.compiled
+	}
+	if c.passed != o.passed {
+		return c.passed > o.passed
+	}
+	return c.changed < o.changed
+}
+
+// solveBestOf запрашивает у модели n вариантов решения одновременно, с разной
+// температурой, оценивает каждый в отдельной копии исходников проекта (сборка,
+// общие тесты задачи и прежние тесты целевого каталога, объём изменений)
+// и применяет к проекту лучший. В историю попадает только он. Синтаксические
+// ошибки, найденные при его применении, дописываются в syntax.
+func solveBestOf(ctx context.Context, client *LLMClient, task domen.Task, repoContext string, history *TaskHistory, n int, syntax *strings.Builder) error {
+	messages, userPrompt, err := client.taskMessages(task, repoContext, history)
+	if err != nil {
+		return err
+	}
+
+	shared := sharedTests(ctx, client, task)
+
+	candidates := make([]*candidate, n)
+	var wg sync.WaitGroup
+	for i := range candidates {
+		client.Report.attempt(StageSolve)
+		cand := &candidate{}
+		candidates[i] = cand
+		wg.Add(1)
+		go func(temperature float64) {
+			defer wg.Done()
+			if cand.output, cand.err = client.chat(ctx, messages, temperature); cand.err != nil {
+				return
+			}
+			if cand.commands, cand.err = ParseCommands(cand.output); cand.err != nil {
+				return
+			}
+			cand.err = scoreCandidate(ctx, client.Workspace, cand, shared)
+		}(float64(i) * candidateTemperatureStep)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	best := 0
+	for i, cand := range candidates {
+		if cand.err != nil {
+			client.Log.Warn(i18n.T("Вариант решения отброшен"), "candidate", i+1, logging.KeyError, cand.err)
+		} else {
+			client.Log.Info(i18n.T("Оценён вариант решения"), "candidate", i+1,
+				"compiled", cand.compiled, "passed", cand.passed, "changed", cand.changed)
+		}
+		if cand.better(candidates[best]) {
+			best = i
+		}
+	}
+	winner := candidates[best]
+	if winner.err != nil {
+		errs := make([]error, 0, n)
+		for _, cand := range candidates {
+			errs = append(errs, cand.err)
+		}
+		return fmt.Errorf(i18n.T("ни один из %d вариантов решения не подошёл: %w"), n, errors.Join(errs...))
+	}
+	client.Log.Info(i18n.T("Выбран вариант решения"), "candidate", best+1)
+
+	history.AddPrompt(userPrompt)
+	history.AddCommands(winner.output)
+	if execErrs := client.applyCommands(ctx, winner.commands, syntax); len(execErrs) > 0 {
+		return fmt.Errorf(i18n.T("ошибка выполнения команды: %w"), execErrs[0])
+	}
+	return nil
+}
+
+// sharedTests просит модель написать тесты по описанию и сигнатуре задачи ещё
+// до решения, в отдельной переписке: на этом общем наборе оцениваются все
+// кандидаты, и ни один из них не проверяет себя сам. Возвращает только команды
+// для файлов тестов. Если тесты получить не удалось, кандидаты оцениваются
+// лишь на прежних тестах проекта.
+func sharedTests(ctx context.Context, client *LLMClient, task domen.Task) []domen.Command {
+	client.Report.attempt(StageSolve)
+	commands, err := generateTests(ctx, client, task, NewTaskHistory())
+	if err != nil {
+		client.Log.Warn(i18n.T("Не удалось получить общие тесты для оценки вариантов решения"), logging.KeyError, err)
+		return nil
+	}
+	var tests []domen.Command
+	for _, cmd := range commands {
+		if strings.HasSuffix(cmd.Path, "_test.go") {
+			tests = append(tests, cmd)
+		}
+	}
+	return tests
+}
+
+// scoreCandidate применяет команды кандидата в свежей копии исходников
+// проекта и оценивает результат: сборку вместе с тестами, число прошедших
+// тестов целевого каталога и объём правки. Тесты, которые написал или изменил
+// сам кандидат, в счёт не идут: тесты запускаются в том виде, в каком они были
+// в проекте до задачи, вместе с общими тестами задачи shared.
+// Ошибка — команды не применились или ctx отменён.
+func scoreCandidate(ctx context.Context, ws Workspace, cand *candidate, shared []domen.Command) error {
+	dir, err := os.MkdirTemp("", "ralf-candidate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := copyGoSources(ws, dir, append(slices.Clone(cand.commands), shared...)); err != nil {
+		return fmt.Errorf(i18n.T("не удалось скопировать проект: %w"), err)
+	}
+
+	copyWs := ws
+	copyWs.Root, copyWs.Sandbox = dir, dir
+	copyWs.Changes = NewChangeSet()
+	// синтаксические ошибки форматирования не отбрасывают кандидата: такой
+	// кандидат просто не соберётся
+	if execErrs, _ := copyWs.ExecuteAll(ctx, cand.commands); len(execErrs) > 0 {
+		return fmt.Errorf(i18n.T("ошибка выполнения команды: %w"), execErrs[0])
+	}
+
+	var authoredTests []string
+	for _, p := range copyWs.Changes.Paths() {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			continue
+		}
+		before, _ := os.ReadFile(filepath.Join(ws.Root, rel))
+		after, _ := os.ReadFile(p)
+		cand.changed += changedLines(before, after)
+		if strings.HasSuffix(p, "_test.go") {
+			authoredTests = append(authoredTests, rel)
+		}
+	}
+	if _, err := copyWs.CompileTests(ctx); err != nil {
+		// несобирающийся кандидат остаётся в выборе с нулевым счётом
+		if ctx.Err() != nil {
+			return err
+		}
+		return nil
+	}
+	cand.compiled = true
+
+	// тесты кандидата возвращаются к состоянию проекта до задачи
+	for _, rel := range authoredTests {
+		orig, err := readState(filepath.Join(ws.Root, rel))
+		if err != nil {
+			return err
+		}
+		if err := orig.write(filepath.Join(dir, rel)); err != nil {
+			return err
+		}
+	}
+	// общие тесты одинаковы для всех кандидатов, поэтому их ошибки кандидата не отбрасывают
+	copyWs.ExecuteAll(ctx, shared)
+	cand.passed, err = countPassedTests(ctx, dir, ws.packages(), ws.CompileTimeout)
+	return err
+}
+
+// copyGoSources копирует в dst то, что нужно для сборки и тестов кандидата:
+// файлы go.mod, go.sum и go.work, каталоги с .go-файлами целиком (без
+// подкаталогов), каталоги testdata и vendor и файлы, которые читают команды кандидата.
+// Скрытые каталоги и служебные пути ws.Excluded пропускаются.
+func copyGoSources(ws Workspace, dst string, commands []domen.Command) error {
+	needed := make(map[string]bool)
+	for _, cmd := range commands {
+		for _, p := range []string{cmd.Path, cmd.SrcPath} {
+			if p != "" {
+				needed[filepath.ToSlash(filepath.Clean(p))] = true
+			}
+		}
+	}
+	goDirs := make(map[string]bool)
+	for _, dir := range goPackageDirs(ws.Root) {
+		goDirs[dir] = true
+	}
+
+	return filepath.WalkDir(ws.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(ws.Root, path)
+		if err != nil {
+			return err
+		}
+		slashRel := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if path != ws.Root && (strings.HasPrefix(d.Name(), ".") || slices.Contains(ws.Excluded, slashRel)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		parts := strings.Split(slashRel, "/")
+		wholeTree := parts[0] == "vendor" || slices.Contains(parts, "testdata")
+		switch d.Name() {
+		case "go.mod", "go.sum", "go.work", "go.work.sum":
+		default:
+			if !goDirs[filepath.Dir(path)] && !wholeTree && !needed[slashRel] {
+				return nil
+			}
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		state, err := readState(path)
+		if err != nil {
+			return err
+		}
+		return state.write(filepath.Join(dst, rel))
+	})
+}
+
+// changedLines оценивает объём правки: число строк, которые есть только
+// в одной из версий файла (порядок строк не учитывается).
+func changedLines(before, after []byte) int {
+	count := make(map[string]int)
+	for _, line := range splitLines(before) {
+		count[string(line)]++
+	}
+	changed := 0
+	for _, line := range splitLines(after) {
+		if count[string(line)] > 0 {
+			count[string(line)]--
+		} else {
+			changed++
+		}
+	}
+	for _, n := range count {
+		changed += n
+	}
+	return changed
+}
+
+// splitLines делит содержимое файла на строки; у пустого файла строк нет.
+func splitLines(data []byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
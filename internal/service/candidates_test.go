@@ -0,0 +1,180 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func Test_runTask_bestOf(t *testing.T) {
+	newSandbox(t)
+	srv := lmstudiotest.NewServer(t)
+	// сначала — общие тесты задачи, затем кандидаты в произвольном порядке: выбор
+	// зависит только от их оценки. Заглушка собирается и меньше, но не проходит
+	// общие тесты, а собственные тесты кандидату счёта не добавляют
+	stubMain := domen.Command{Type: "create", Path: "prog/main.go", Content: "package main\n\nfunc Greeting(name string) string { return \"\" }\n\nfunc main() {}\n"}
+	stubTest := domen.Command{Type: "create", Path: "prog/greeting_test.go", Content: "package main\n\nimport \"testing\"\n\nfunc TestStub(t *testing.T) {}\n"}
+	srv.ReplyCommands(createGoodTest)
+	srv.ReplyCommands(createBadMain).ReplyCommands(createGoodMain).ReplyCommands(stubMain, stubTest)
+	srv.ReplyCommands(createGoodTest)
+	report := NewTaskReport(greetingTask)
+
+	cfg := domen.Config{Endpoint: srv.Endpoint(), MaxTaskAttempts: 1, Candidates: 3}
+	if err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil); err != nil {
+		t.Fatalf("runTask() error = %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 5 {
+		t.Fatalf("runTask() made %d requests, want 5", len(requests))
+	}
+	var temperatures []float64
+	for _, req := range requests[1:4] {
+		temperatures = append(temperatures, req.Temperature)
+	}
+	slices.Sort(temperatures)
+	for i, want := range []float64{0, 0.3, 0.6} {
+		if math.Abs(temperatures[i]-want) > 1e-9 {
+			t.Errorf("candidate temperatures = %v, want 0, 0.3, 0.6", temperatures)
+			break
+		}
+	}
+	tests := requests[4].Messages
+	if !containsMessage(tests, "assistant", "Hello, World!") || containsMessage(tests, "assistant", "greeting_test.go") ||
+		containsMessage(tests, "assistant", "greet(name)") || containsMessage(tests, "assistant", "TestStub") {
+		t.Errorf("history after solve does not hold exactly the winning candidate")
+	}
+	if got := report.Stages[StageSolve]; got == nil || *got != (StageReport{Attempts: 4, Passed: true}) {
+		t.Errorf("solve stage = %+v", got)
+	}
+	if report.Stages[StageCompile].Attempts != 0 {
+		t.Errorf("winner needed %d compile fixes, want 0", report.Stages[StageCompile].Attempts)
+	}
+}
+
+func TestCandidate_better(t *testing.T) {
+	failed := &candidate{err: errors.New("не JSON")}
+	broken := &candidate{changed: 1}
+	tests := []struct {
+		name string
+		c, o *candidate
+		want bool
+	}{
+		{name: "valid beats failed", c: broken, o: failed, want: true},
+		{name: "compiled beats broken", c: &candidate{compiled: true, changed: 50}, o: broken, want: true},
+		{name: "more tests pass", c: &candidate{compiled: true, passed: 3}, o: &candidate{compiled: true, passed: 2}, want: true},
+		{name: "fewer lines on a tie", c: &candidate{compiled: true, passed: 2, changed: 9}, o: &candidate{compiled: true, passed: 2, changed: 10}, want: true},
+		{name: "equal keeps the first", c: broken, o: broken, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.better(tt.o); got != tt.want {
+				t.Errorf("better() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_changedLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          int
+	}{
+		{name: "new file", before: "", after: "a\nb\n", want: 2},
+		{name: "same", before: "a\nb\n", after: "a\nb\n", want: 0},
+		{name: "one line edited", before: "a\nb\nc\n", after: "a\nB\nc\n", want: 2},
+		{name: "deleted file", before: "a\nb\n", after: "", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changedLines([]byte(tt.before), []byte(tt.after)); got != tt.want {
+				t.Errorf("changedLines() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_scoreCandidate(t *testing.T) {
+	newSandbox(t)
+	writeFile(t, filepath.Join("prog", "calc", "add.go"), "package calc\n\nfunc Add(a, b int) int { return 0 }\n")
+	writeFile(t, filepath.Join("prog", "calc", "add_test.go"), "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fail()\n\t}\n}\n")
+	writeFile(t, filepath.Join("docs", "notes.txt"), "не нужен для сборки\n")
+	ws, err := NewWorkspace(domen.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trivialTests := "package calc\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n\nfunc TestB(t *testing.T) {}\n\nfunc TestC(t *testing.T) {}\n"
+	tests := []struct {
+		name       string
+		commands   []domen.Command
+		shared     []domen.Command
+		wantPassed int
+	}{
+		{
+			name:       "fixes the code",
+			commands:   []domen.Command{{Type: "edit", Path: "prog/calc/add.go", Content: "package calc\n\nfunc Add(a, b int) int { return a + b }\n"}},
+			wantPassed: 1,
+		},
+		{
+			name: "own tests are not counted",
+			commands: []domen.Command{
+				{Type: "create", Path: "prog/calc/trivial_test.go", Content: trivialTests},
+				{Type: "edit", Path: "prog/calc/add_test.go", Content: "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {}\n"},
+			},
+			wantPassed: 0,
+		},
+		{
+			name:     "shared tests are counted",
+			commands: []domen.Command{{Type: "edit", Path: "prog/calc/add.go", Content: "package calc\n\nfunc Add(a, b int) int { return a + b }\n"}},
+			shared: []domen.Command{{Type: "create", Path: "prog/calc/shared_test.go",
+				Content: "package calc\n\nimport \"testing\"\n\nfunc TestShared(t *testing.T) {\n\tif Add(2, 2) != 4 {\n\t\tt.Fail()\n\t}\n}\n"}},
+			wantPassed: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cand := &candidate{commands: tt.commands}
+			if err := scoreCandidate(t.Context(), ws, cand, tt.shared); err != nil {
+				t.Fatalf("scoreCandidate() error = %v", err)
+			}
+			if !cand.compiled || cand.passed != tt.wantPassed {
+				t.Errorf("candidate compiled = %v, passed = %d; want compiled, %d passed", cand.compiled, cand.passed, tt.wantPassed)
+			}
+		})
+	}
+}
+
+func Test_copyGoSources(t *testing.T) {
+	newSandbox(t)
+	writeFile(t, filepath.Join("prog", "main.go"), "package main\n")
+	writeFile(t, filepath.Join("prog", "testdata", "in.txt"), "data\n")
+	writeFile(t, filepath.Join("docs", "notes.txt"), "notes\n")
+	writeFile(t, filepath.Join("docs", "readme.md"), "readme\n")
+	ws, err := NewWorkspace(domen.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	if err := copyGoSources(ws, dst, []domen.Command{{Type: "edit", Path: "docs/readme.md"}}); err != nil {
+		t.Fatalf("copyGoSources() error = %v", err)
+	}
+	for name, want := range map[string]bool{
+		"go.mod":               true,
+		"prog/main.go":         true,
+		"prog/testdata/in.txt": true,
+		"docs/readme.md":       true,
+		"docs/notes.txt":       false,
+	} {
+		_, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+		if (err == nil) != want {
+			t.Errorf("%s copied = %v, want %v", name, err == nil, want)
+		}
+	}
+}
@@ -1,50 +1,120 @@
 package service
 
 import (
+	"Ralf/internal/i18n"
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // Compile выполняет компиляцию Go-кода по указанному пути (файл или директория проекта)
 // после применения атомарных команд Command. Использует go build для выявления
 // максимального количества критических ошибок компиляции (синтаксис, типы,
 // неиспользуемые идентификаторы и т.д.).
-func Compile(path string) (string, error) {
+func Compile(ctx context.Context, path string) (string, error) {
 	// Определяем рабочую директорию
 	dir := filepath.Dir(path)
 	if dir == "." || dir == "" || dir == "/" {
 		dir = path
 	}
 
-	// Кросс-платформенный путь к /dev/null
-	devNull := "/dev/null"
-	if runtime.GOOS == "windows" {
-		devNull = "NUL"
-	}
-
 	// Формируем аргументы: для .go-файла собираем только его,
 	// иначе весь пакет и подпакеты
-	args := []string{"build", "-o", devNull, "./..."}
+	target := "./..."
 	if strings.HasSuffix(strings.ToLower(path), ".go") {
-		args = []string{"build", "-o", devNull, path}
+		target = path
+	}
+	return runBuild(ctx, dir, 0, i18n.T("Ошибка компиляции."), "build", "-o", devNull(), target)
+}
+
+// compileDir собирает все пакеты модуля в каталоге dir.
+func compileDir(ctx context.Context, dir string, timeout time.Duration) (string, error) {
+	return runBuild(ctx, dir, timeout, i18n.T("Ошибка компиляции."), "build", "-o", devNull(), "./...")
+}
+
+// CompileTests компилирует тесты проекта без запуска (go test -run ^$),
+// так как go build не собирает файлы _test.go.
+func CompileTests(ctx context.Context, path string) (string, error) {
+	return compileTestsDir(ctx, path, 0)
+}
+
+func compileTestsDir(ctx context.Context, dir string, timeout time.Duration) (string, error) {
+	return runBuild(ctx, dir, timeout, i18n.T("Ошибка компиляции тестов."), "test", "-count=1", "-run", "^$", "./...")
+}
+
+// devNull возвращает кросс-платформенный путь к /dev/null.
+func devNull() string {
+	if runtime.GOOS == "windows" {
+		return "NUL"
 	}
+	return "/dev/null"
+}
 
-	cmd := exec.Command("go", args...)
+// runBuild запускает go с аргументами args в каталоге dir и возвращает вывод
+// (stdout + stderr) как лог ошибки, если команда завершилась неудачно.
+// Нулевой timeout — без ограничения времени. Отмена parent прерывает go
+// и возвращается как ошибка parent.Err(), а не как ошибка сборки.
+func runBuild(parent context.Context, dir string, timeout time.Duration, failure string, args ...string) (string, error) {
+	ctx := parent
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "go", args...)
 	cmd.Dir = dir
 
 	// Захватываем весь вывод (stdout + stderr)
 	output, err := cmd.CombinedOutput()
-
 	if err != nil {
+		if parent.Err() != nil {
+			return "", fmt.Errorf(i18n.T("go %s прерван: %w"), args[0], parent.Err())
+		}
 		compileLog := string(output)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			compileLog += fmt.Sprintf(i18n.T("\nпревышено время ожидания go %s: %s"), args[0], timeout)
+		}
 		if compileLog == "" {
 			compileLog = err.Error()
 		}
-		return compileLog, errors.New("Ошибка компиляции.")
+		return compileLog, errors.New(failure)
 	}
-
 	return "", nil
 }
+
+// countPassedTests запускает тесты пакетов pattern модуля в каталоге dir
+// (go test -json) и возвращает число прошедших тестов. Упавшие тесты и ошибки
+// сборки дают меньший счёт, а не ошибку: ошибкой считается только отмена ctx.
+func countPassedTests(ctx context.Context, dir, pattern string, timeout time.Duration) (int, error) {
+	testCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		testCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(testCtx, "go", "test", "-json", "-count=1", pattern)
+	cmd.Dir = dir
+	output, _ := cmd.Output()
+	if ctx.Err() != nil {
+		return 0, fmt.Errorf(i18n.T("go %s прерван: %w"), "test", ctx.Err())
+	}
+
+	passed := 0
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		var event struct {
+			Action string
+			Test   string
+		}
+		if json.Unmarshal(line, &event) == nil && event.Action == "pass" && event.Test != "" {
+			passed++
+		}
+	}
+	return passed, nil
+}
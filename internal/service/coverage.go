@@ -0,0 +1,314 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"Ralf/internal/prompts"
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxUncoveredRanges — сколько непокрытых участков показывать модели за раз.
+const maxUncoveredRanges = 20
+
+// coverageFailureLines — сколько строк вывода упавших тестов показывать модели.
+const coverageFailureLines = 60
+
+// lineRange — строки файла с from по to включительно.
+type lineRange struct {
+	from, to int
+}
+
+// Coverage — покрытие кода задачи тестами по профилю go test -coverprofile.
+type Coverage struct {
+	Statements int                    // всего операторов в измеренных файлах
+	Covered    int                    // выполненных тестами
+	Uncovered  map[string][]lineRange // непокрытые строки по файлам (пути относительно корня проекта)
+	Failed     string                 // вывод go test, если тесты не прошли: профиль тогда неполный
+}
+
+// Percent возвращает долю покрытых операторов в процентах. Пустой профиль
+// (ни одного оператора) даёт 0: покрытие не подтверждено.
+func (c Coverage) Percent() float64 {
+	if c.Statements == 0 {
+		return 0
+	}
+	return float64(c.Covered) * 100 / float64(c.Statements)
+}
+
+// coverageStage измеряет покрытие кода задачи тестами и записывает его в отчёт.
+// Пока покрытие ниже minCoverage, модель получает непокрытые строки и дописывает
+// тест-кейсы (не больше maxRounds раз); сборка тестов после каждого дополнения
+// доводится до успеха (не больше maxCompileFixes исправлений). Упавшие тесты
+// не выполняют этап при любом пороге: модель получает их вывод и исправляет их
+// в тех же раундах. Нулевой minCoverage отключает только требование к проценту.
+func coverageStage(ctx context.Context, client *LLMClient, task domen.Task, history *TaskHistory, minCoverage float64, maxRounds, maxCompileFixes int) error {
+	ws := client.Workspace
+	testFile := testFilePath(ws.OutputDir, task)
+	for round := 0; ; round++ {
+		cov, err := ws.MeasureCoverage(ctx)
+		if err != nil {
+			return err
+		}
+		client.Report.testRun(cov.Failed == "")
+		percent := cov.Percent()
+		client.Report.coverage(percent)
+		if cov.Failed == "" && (minCoverage <= 0 || percent >= minCoverage) {
+			client.Log.Info(i18n.T("Покрытие тестами"), "coverage", fmt.Sprintf("%.1f%%", percent))
+			client.Report.pass(StageCoverage)
+			return nil
+		}
+		if round >= maxRounds {
+			if cov.Failed != "" {
+				return fmt.Errorf(i18n.T("тесты не прошли при измерении покрытия:\n%s"), truncateLog(cov.Failed, coverageFailureLines))
+			}
+			return fmt.Errorf(i18n.T("покрытие тестами %.1f%% ниже требуемого %.1f%%"), percent, minCoverage)
+		}
+
+		log := client.Log.With(logging.KeyStage, StageCoverage, logging.KeyAttempt, round+1)
+		if cov.Failed != "" {
+			log.Info(i18n.T("Тесты не проходят, просим их исправить"))
+		} else {
+			log.Info(i18n.T("Покрытие ниже порога, просим дополнить тесты"), "coverage", fmt.Sprintf("%.1f%%", percent), "min", minCoverage)
+		}
+		client.Report.attempt(StageCoverage)
+		response, err := client.SendCoverage(ctx, task, cov, minCoverage, round+1, testFile, history)
+		if err != nil {
+			return fmt.Errorf(i18n.T("ошибка получения тестов от LM Studio: %w"), err)
+		}
+		commands, err := ParseCommands(response)
+		if err != nil {
+			log.Warn(i18n.T("Не удалось разобрать исправления"), logging.KeyError, err)
+			continue
+		}
+		var syntax strings.Builder
+		for _, execErr := range client.applyCommands(ctx, commands, &syntax) {
+			log.Warn(i18n.T("Исправление не применилось"), logging.KeyError, execErr)
+		}
+		if err := fixLoop(ctx, client, history, StageTestCompile, ws.CompileTests, testFile, maxCompileFixes, syntax.String()); err != nil {
+			return err
+		}
+	}
+}
+
+// SendCoverage просит модель дописать тесты для непокрытых строк. Запрос
+// отправляется вместе с историей задачи; он и ответ модели дописываются в историю.
+func (c *LLMClient) SendCoverage(ctx context.Context, task domen.Task, cov Coverage, threshold float64, round int, testFile string, history *TaskHistory) (string, error) {
+	system, err := c.systemPrompt()
+	if err != nil {
+		return "", err
+	}
+	testCode := ""
+	if data, err := c.Workspace.ReadFile(testFile); err == nil {
+		testCode = string(data)
+	}
+	prompt, err := c.Prompts.Coverage(prompts.CoverageData{
+		Task:      task,
+		Round:     round,
+		Coverage:  cov.Percent(),
+		Threshold: threshold,
+		Uncovered: c.Workspace.uncoveredCode(cov),
+		Failures:  truncateLog(cov.Failed, coverageFailureLines),
+		TestFile:  testFile,
+		TestCode:  testCode,
+	})
+	if err != nil {
+		return "", err
+	}
+	remaining := c.promptBudget() - countMessagesTokens(c.Tokenizer, []Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: prompt},
+	})
+	messages := []Message{{Role: "system", Content: system}}
+	messages = append(messages, history.Messages(remaining, c.Tokenizer)...)
+	messages = append(messages, Message{Role: "user", Content: prompt})
+
+	response, err := c.chat(ctx, messages, 0.1)
+	if err != nil {
+		return "", err
+	}
+	history.AddDiagnostic(prompt)
+	history.AddCommands(response)
+	return response, nil
+}
+
+// uncoveredCode описывает непокрытые участки для модели: место и код с номерами строк.
+func (w Workspace) uncoveredCode(cov Coverage) []string {
+	files := make([]string, 0, len(cov.Uncovered))
+	for file := range cov.Uncovered {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	var result []string
+	for _, file := range files {
+		lines, _ := readLines(filepath.Join(w.Root, filepath.FromSlash(file)))
+		for _, r := range cov.Uncovered[file] {
+			if len(result) == maxUncoveredRanges {
+				return result
+			}
+			var sb strings.Builder
+			fmt.Fprintf(&sb, "%s:%d-%d", file, r.from, r.to)
+			for n := r.from; n <= r.to && n <= len(lines); n++ {
+				fmt.Fprintf(&sb, "\n%4d | %s", n, lines[n-1])
+			}
+			result = append(result, sb.String())
+		}
+	}
+	return result
+}
+
+// MeasureCoverage запускает тесты целевого каталога с -coverprofile и считает
+// покрытие файлов, изменённых задачей (без файлов тестов); если изменения не
+// отслеживаются — всех файлов каталога. Упавшие тесты не мешают измерению,
+// их вывод попадает в Coverage.Failed: ошибка — только если профиль не получен.
+func (w Workspace) MeasureCoverage(ctx context.Context) (Coverage, error) {
+	profile, err := os.CreateTemp("", "ralf-cover-*.out")
+	if err != nil {
+		return Coverage{}, err
+	}
+	profile.Close()
+	defer os.Remove(profile.Name())
+
+	runCtx := ctx
+	if w.CompileTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, w.CompileTimeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(runCtx, "go", "test", "-count=1", "-coverprofile="+profile.Name(), w.packages())
+	cmd.Dir = w.Root
+	output, testErr := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return Coverage{}, fmt.Errorf(i18n.T("go %s прерван: %w"), "test", ctx.Err())
+	}
+	failed := ""
+	if testErr != nil {
+		failed = strings.TrimSpace(string(output))
+		w.logger().Warn(i18n.T("Тесты не прошли при измерении покрытия"), logging.KeyError, failed)
+	}
+
+	dirs, err := w.packageDirs(ctx)
+	if err != nil {
+		return Coverage{}, err
+	}
+	var only map[string]bool
+	if w.Changes != nil {
+		only = make(map[string]bool)
+		for _, rel := range w.Rel(w.Changes.Paths()) {
+			only[rel] = true
+		}
+	}
+	cov, err := parseCoverProfile(profile.Name(), func(name string) (string, bool) {
+		dir, ok := dirs[path.Dir(name)]
+		if !ok {
+			return "", false
+		}
+		rel := w.Rel([]string{filepath.Join(dir, path.Base(name))})[0]
+		return rel, only == nil || only[rel]
+	})
+	if err != nil {
+		return Coverage{}, fmt.Errorf(i18n.T("не удалось получить профиль покрытия: %w\n%s"), err, output)
+	}
+	cov.Failed = failed
+	return cov, nil
+}
+
+// packageDirs возвращает каталоги пакетов целевого каталога по путям импорта.
+func (w Workspace) packageDirs(ctx context.Context) (map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "go", "list", "-e", "-f", "{{.ImportPath}} {{.Dir}}", w.packages())
+	cmd.Dir = w.Root
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf(i18n.T("не удалось получить список пакетов: %w"), err)
+	}
+	dirs := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if importPath, dir, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			dirs[importPath] = dir
+		}
+	}
+	return dirs, nil
+}
+
+// parseCoverProfile читает профиль покрытия. resolve переводит имя файла из
+// профиля (путь импорта пакета и имя файла) в путь для отчёта и сообщает,
+// учитывать ли файл. Повторы одного блока объединяются.
+func parseCoverProfile(profile string, resolve func(name string) (string, bool)) (Coverage, error) {
+	f, err := os.Open(profile)
+	if err != nil {
+		return Coverage{}, err
+	}
+	defer f.Close()
+
+	type block struct {
+		file       string
+		start, end int
+	}
+	statements := make(map[block]int)
+	covered := make(map[block]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// имя/файл.go:начало.колонка,конец.колонка операторов счётчик
+		name, rest, ok := strings.Cut(line, ":")
+		fields := strings.Fields(rest)
+		if !ok || len(fields) != 3 {
+			return Coverage{}, fmt.Errorf(i18n.T("неверная строка профиля покрытия: %q"), line)
+		}
+		file, use := resolve(name)
+		if !use {
+			continue
+		}
+		from, to, _ := strings.Cut(fields[0], ",")
+		start, _ := strconv.Atoi(strings.Split(from, ".")[0])
+		end, _ := strconv.Atoi(strings.Split(to, ".")[0])
+		num, _ := strconv.Atoi(fields[1])
+		count, _ := strconv.Atoi(fields[2])
+		b := block{file: file, start: start, end: end}
+		statements[b] = num
+		covered[b] = covered[b] || count > 0
+	}
+	if err := scanner.Err(); err != nil {
+		return Coverage{}, err
+	}
+
+	cov := Coverage{Uncovered: make(map[string][]lineRange)}
+	for b, num := range statements {
+		cov.Statements += num
+		if covered[b] {
+			cov.Covered += num
+		} else if num > 0 {
+			cov.Uncovered[b.file] = append(cov.Uncovered[b.file], lineRange{b.start, b.end})
+		}
+	}
+	for file, ranges := range cov.Uncovered {
+		cov.Uncovered[file] = mergeRanges(ranges)
+	}
+	return cov, nil
+}
+
+// mergeRanges сортирует участки и объединяет пересекающиеся и соседние.
+func mergeRanges(ranges []lineRange) []lineRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].from < ranges[j].from })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.from <= merged[n-1].to+1 {
+			merged[n-1].to = max(merged[n-1].to, r.to)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
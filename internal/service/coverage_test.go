@@ -0,0 +1,104 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fullTestCode = "package main\n\nimport \"testing\"\n\nfunc TestGreeting(t *testing.T) {\n\ttests := []struct{ name, want string }{\n\t\t{\"\", \"Hello, World!\"},\n\t\t{\"Bob\", \"Hello, Bob!\"},\n\t}\n\tfor _, tt := range tests {\n\t\tif got := Greeting(tt.name); got != tt.want {\n\t\t\tt.Errorf(\"Greeting(%q) = %q\", tt.name, got)\n\t\t}\n\t}\n}\n"
+
+func Test_runTask_coverage(t *testing.T) {
+	tests := []struct {
+		name         string
+		minCoverage  float64
+		wantRequests int
+		wantCoverage float64
+		wantAttempts int
+		testCode     string // первые тесты модели (пусто — goodTestCode)
+		wantPrompt   string // что запрос на дополнение показывает модели
+	}{
+		{name: "measured only", wantRequests: 2, wantCoverage: 200.0 / 3},
+		{name: "more tests requested", minCoverage: 90, wantRequests: 3, wantCoverage: 100, wantAttempts: 1,
+			wantPrompt: `return "Hello, " + name + "!"`},
+		// покрытие 66.7% выше порога, но упавший тест его не засчитывает
+		{name: "failing tests do not count", minCoverage: 50, wantRequests: 3, wantCoverage: 100, wantAttempts: 1,
+			testCode: strings.Replace(goodTestCode, `"Hello, World!"`, `"Hi"`, 1), wantPrompt: "--- FAIL: TestGreeting"},
+		// без порога покрытия упавшие тесты всё равно исправляются
+		{name: "failing tests without a threshold", wantRequests: 3, wantCoverage: 100, wantAttempts: 1,
+			testCode: strings.Replace(goodTestCode, `"Hello, World!"`, `"Hi"`, 1), wantPrompt: "--- FAIL: TestGreeting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSandbox(t)
+			srv := lmstudiotest.NewServer(t)
+			testCode := tt.testCode
+			if testCode == "" {
+				testCode = goodTestCode
+			}
+			srv.ReplyCommands(createGoodMain).ReplyCommands(domen.Command{Type: "create", Path: "prog/greeting_test.go", Content: testCode})
+			if tt.wantRequests > 2 {
+				srv.ReplyCommands(domen.Command{Type: "edit", Path: "prog/greeting_test.go", Content: fullTestCode})
+			}
+			report := NewTaskReport(greetingTask)
+
+			cfg := domen.Config{Endpoint: srv.Endpoint(), MaxTaskAttempts: 1, MinCoverage: tt.minCoverage}
+			if err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil); err != nil {
+				t.Fatalf("runTask() error = %v", err)
+			}
+
+			requests := srv.Requests()
+			if len(requests) != tt.wantRequests {
+				t.Fatalf("runTask() made %d requests, want %d", len(requests), tt.wantRequests)
+			}
+			if tt.wantPrompt != "" && !containsMessage(requests[2].Messages, "user", tt.wantPrompt) {
+				t.Errorf("coverage request does not show %q", tt.wantPrompt)
+			}
+			if report.Coverage == nil || math.Abs(*report.Coverage-tt.wantCoverage) > 1e-9 {
+				t.Errorf("report coverage = %v, want %.1f", report.Coverage, tt.wantCoverage)
+			}
+			if got := report.Stages[StageCoverage]; got == nil || *got != (StageReport{Attempts: tt.wantAttempts, Passed: true}) {
+				t.Errorf("coverage stage = %+v", got)
+			}
+		})
+	}
+}
+
+func Test_parseCoverProfile(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "cover.out")
+	data := "mode: set\n" +
+		"sandbox/prog/main.go:3.34,4.16 1 1\n" +
+		"sandbox/prog/main.go:4.16,6.3 1 0\n" +
+		"sandbox/prog/main.go:7.2,7.30 1 0\n" +
+		"sandbox/prog/main.go:7.2,7.30 1 1\n" + // тот же блок из другого тестового бинарника
+		"sandbox/prog/main.go:10.2,12.3 2 0\n" +
+		"sandbox/other/x.go:1.1,2.2 5 0\n"
+	if err := os.WriteFile(profile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseCoverProfile(profile, func(name string) (string, bool) {
+		return strings.TrimPrefix(name, "sandbox/"), strings.HasPrefix(name, "sandbox/prog/")
+	})
+	if err != nil {
+		t.Fatalf("parseCoverProfile() error = %v", err)
+	}
+	want := Coverage{
+		Statements: 5,
+		Covered:    2,
+		Uncovered:  map[string][]lineRange{"prog/main.go": {{4, 6}, {10, 12}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCoverProfile() = %+v, want %+v", got, want)
+	}
+	if p := got.Percent(); p != 40 {
+		t.Errorf("Percent() = %v, want 40", p)
+	}
+	if p := (Coverage{}).Percent(); p != 0 {
+		t.Errorf("Percent() of an empty profile = %v, want 0", p)
+	}
+}
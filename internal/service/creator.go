@@ -2,27 +2,37 @@ package service
 
 import (
 	"Ralf/domen"
+	"Ralf/internal/i18n"
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// tasksFileMu сериализует изменения файла задач: статусы могут обновляться
+// из нескольких горутин, а каждое обновление переписывает файл целиком.
+var tasksFileMu sync.Mutex
+
 // UpdateTaskStatus изменяет статус задачи с указанным номером в файле.
 // Возвращает nil при успешной замене, иначе ошибку с описанием.
 func UpdateTaskStatus(filePath string, taskNum int, newStatus domen.TaskStatus) error {
+	tasksFileMu.Lock()
+	defer tasksFileMu.Unlock()
+
 	// 1. Открываем исходный файл для чтения
 	inputFile, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("не удалось открыть файл для чтения: %w", err)
+		return fmt.Errorf(i18n.T("не удалось открыть файл для чтения: %w"), err)
 	}
 	defer inputFile.Close()
 
 	// 2. Создаём временный файл для записи обновлённого содержимого
 	tempFile, err := os.CreateTemp("", "tasks_*.txt")
 	if err != nil {
-		return fmt.Errorf("не удалось создать временный файл: %w", err)
+		return fmt.Errorf(i18n.T("не удалось создать временный файл: %w"), err)
 	}
 	tempFileName := tempFile.Name()
 	defer tempFile.Close()
@@ -82,7 +92,7 @@ func UpdateTaskStatus(filePath string, taskNum int, newStatus domen.TaskStatus)
 					for _, l := range updatedLines {
 						_, err := tempFile.WriteString(l + "\n")
 						if err != nil {
-							return fmt.Errorf("ошибка записи во временный файл: %w", err)
+							return fmt.Errorf(i18n.T("ошибка записи во временный файл: %w"), err)
 						}
 					}
 				} else {
@@ -90,7 +100,7 @@ func UpdateTaskStatus(filePath string, taskNum int, newStatus domen.TaskStatus)
 					for _, l := range taskLines {
 						_, err := tempFile.WriteString(l + "\n")
 						if err != nil {
-							return fmt.Errorf("ошибка записи во временный файл: %w", err)
+							return fmt.Errorf(i18n.T("ошибка записи во временный файл: %w"), err)
 						}
 					}
 				}
@@ -104,21 +114,21 @@ func UpdateTaskStatus(filePath string, taskNum int, newStatus domen.TaskStatus)
 			// Строка вне задачи — просто копируем
 			_, err := tempFile.WriteString(line + "\n")
 			if err != nil {
-				return fmt.Errorf("ошибка записи во временный файл: %w", err)
+				return fmt.Errorf(i18n.T("ошибка записи во временный файл: %w"), err)
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("ошибка чтения исходного файла: %w", err)
+		return fmt.Errorf(i18n.T("ошибка чтения исходного файла: %w"), err)
 	}
 
 	// 4. Проверяем, что задача была найдена и статус обновлён
 	if !taskFound {
-		return fmt.Errorf("не получилось поменять статус задачи № %d в файле %s: задача не найдена", taskNum, filePath)
+		return fmt.Errorf(i18n.T("не получилось поменять статус задачи № %d в файле %s: задача не найдена"), taskNum, filePath)
 	}
 	if !statusUpdated {
-		return fmt.Errorf("не получилось поменять статус задачи № %d в файле %s: поле статуса не найдено", taskNum, filePath)
+		return fmt.Errorf(i18n.T("не получилось поменять статус задачи № %d в файле %s: поле статуса не найдено"), taskNum, filePath)
 	}
 
 	// 5. Закрываем файлы перед заменой
@@ -127,12 +137,61 @@ func UpdateTaskStatus(filePath string, taskNum int, newStatus domen.TaskStatus)
 
 	// 6. Заменяем исходный файл временным
 	if err := os.Rename(tempFileName, filePath); err != nil {
-		return fmt.Errorf("не удалось заменить исходный файл: %w", err)
+		return fmt.Errorf(i18n.T("не удалось заменить исходный файл: %w"), err)
 	}
 
 	return nil
 }
 
+// UpdateTaskAttempts записывает в задачу с указанным номером, сколько попыток
+// на неё ушло. Строка «использовано попыток» заменяется или добавляется перед
+// концом задачи.
+func UpdateTaskAttempts(filePath string, taskNum, attempts int) error {
+	tasksFileMu.Lock()
+	defer tasksFileMu.Unlock()
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf(i18n.T("не удалось открыть файл для чтения: %w"), err)
+	}
+	attemptsLine := fmt.Sprintf("использовано попыток:%d", attempts)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	result := make([]string, 0, len(lines)+1)
+	currentTaskNum, written, found := 0, false, false
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmedLine, "начало задачи:"):
+			currentTaskNum, written = 0, false
+		case strings.HasPrefix(trimmedLine, "номер задачи:"):
+			currentTaskNum, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(trimmedLine, "номер задачи:")))
+		case currentTaskNum == taskNum && strings.HasPrefix(trimmedLine, "использовано попыток:"):
+			if !written {
+				result = append(result, attemptsLine)
+				written = true
+			}
+			continue
+		case currentTaskNum == taskNum && strings.HasPrefix(trimmedLine, "конец задачи."):
+			if !written {
+				result = append(result, attemptsLine)
+			}
+			found = true
+		}
+		result = append(result, line)
+	}
+	if !found {
+		return fmt.Errorf(i18n.T("не получилось записать число попыток задачи № %d в файл %s: задача не найдена"), taskNum, filePath)
+	}
+	tmp := filePath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(result, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf(i18n.T("ошибка записи во временный файл: %w"), err)
+	}
+	if err := os.Rename(tmp, filePath); err != nil {
+		return fmt.Errorf(i18n.T("не удалось заменить исходный файл: %w"), err)
+	}
+	return nil
+}
+
 // updateStatusInLine заменяет старое значение статуса на новое в строке.
 func updateStatusInLine(line string, newStatus domen.TaskStatus) string {
 	parts := strings.SplitN(line, ":", 2)
@@ -142,3 +201,66 @@ func updateStatusInLine(line string, newStatus domen.TaskStatus) string {
 	// Оставляем ключ без изменений, подставляем новое значение статуса
 	return fmt.Sprintf("%s:%s", parts[0], string(newStatus))
 }
+
+// AppendTask дописывает задачу в конец файла задач. Если номер не задан,
+// задаче присваивается следующий свободный номер; пустой статус заменяется на new.
+// Возвращает задачу в том виде, в котором она записана.
+func AppendTask(filePath string, task domen.Task) (domen.Task, error) {
+	tasksFileMu.Lock()
+	defer tasksFileMu.Unlock()
+
+	existing, err := ReadTasks(filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return domen.Task{}, err
+	}
+	maxNum := 0
+	for _, t := range existing {
+		if t.Num == task.Num && task.Num != 0 {
+			return domen.Task{}, fmt.Errorf(i18n.T("номер задачи %d повторяется"), task.Num)
+		}
+		maxNum = max(maxNum, t.Num)
+	}
+	if task.Num == 0 {
+		task.Num = maxNum + 1
+	}
+	if task.Status == "" {
+		task.Status = domen.StatusNew
+	}
+
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return domen.Task{}, fmt.Errorf(i18n.T("не удалось открыть файл для записи: %w"), err)
+	}
+	defer file.Close()
+
+	// Файл может не заканчиваться переводом строки — тогда добавляем его
+	var sb strings.Builder
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			sb.WriteString("\n")
+		}
+	}
+	// Формат файла построчный, поэтому переводы строк в значениях заменяются пробелами
+	oneLine := strings.NewReplacer("\r\n", " ", "\n", " ").Replace
+	sb.WriteString("начало задачи:\n")
+	sb.WriteString(fmt.Sprintf("номер задачи:%d\n", task.Num))
+	sb.WriteString("описание задачи:" + oneLine(task.Description) + "\n")
+	sb.WriteString("важные моменты:" + oneLine(task.ImportantInfo) + "\n")
+	sb.WriteString("ожидаемый результат:" + oneLine(task.ExpectResult) + "\n")
+	sb.WriteString("тестовые данные:" + oneLine(task.TestsValue) + "\n")
+	sb.WriteString("сигнатура функции:" + oneLine(task.FuncSignature) + "\n")
+	sb.WriteString("статус выполнения:" + string(task.Status) + "\n")
+	if task.Parent != 0 {
+		sb.WriteString(fmt.Sprintf("родительская задача:%d\n", task.Parent))
+	}
+	if task.Attempts != 0 {
+		sb.WriteString(fmt.Sprintf("использовано попыток:%d\n", task.Attempts))
+	}
+	sb.WriteString("конец задачи.\n")
+
+	if _, err := file.WriteString(sb.String()); err != nil {
+		return domen.Task{}, fmt.Errorf(i18n.T("ошибка записи в файл задач: %w"), err)
+	}
+	return task, nil
+}
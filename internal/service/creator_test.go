@@ -2,6 +2,8 @@ package service
 
 import (
 	"Ralf/domen"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -19,17 +21,17 @@ func Test_updateStatusInLine(t *testing.T) {
 		{
 			name: "edit_new_status_to_run",
 			args: args{line: "статус выполнения:new", newStatus: domen.StatusRun},
-			want: "статус выполнения:new",
+			want: "статус выполнения:run",
 		},
 		{
 			name: "edit_new_status_to_error",
 			args: args{line: "статус выполнения:new", newStatus: domen.StatusError},
-			want: "статус выполнения:new",
+			want: "статус выполнения:error",
 		},
 		{
 			name: "edit_new_status_to_ok",
 			args: args{line: "статус выполнения:new", newStatus: domen.StatusOK},
-			want: "статус выполнения:new",
+			want: "статус выполнения:ok",
 		},
 	}
 	for _, tt := range tests {
@@ -152,3 +154,59 @@ func TestUpdateTaskStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendTask(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.txt")
+	// файл без перевода строки в конце
+	if err := os.WriteFile(path, []byte("начало задачи:\nномер задачи:4\nописание задачи:a\nстатус выполнения:ok\nконец задачи."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := AppendTask(path, domen.Task{Description: "новая\nзадача", FuncSignature: "func F()"})
+	if err != nil {
+		t.Fatalf("AppendTask() error = %v", err)
+	}
+	want := domen.Task{Num: 5, Description: "новая\nзадача", FuncSignature: "func F()", Status: domen.StatusNew}
+	if added != want {
+		t.Errorf("AppendTask() = %+v, want %+v", added, want)
+	}
+
+	got, err := FindTask(path, 5)
+	if err != nil {
+		t.Fatalf("FindTask() error = %v", err)
+	}
+	want.Description = "новая задача"
+	if got != want {
+		t.Errorf("FindTask() = %+v, want %+v", got, want)
+	}
+
+	if _, err := AppendTask(path, domen.Task{Num: 4, Description: "дубль"}); err == nil {
+		t.Error("AppendTask() with duplicate number: want error")
+	}
+}
+
+func TestUpdateTaskAttempts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.txt")
+	for _, task := range []domen.Task{{Description: "первая"}, {Description: "вторая"}} {
+		if _, err := AppendTask(path, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// первая запись добавляет строку, повторная — заменяет её
+	for _, attempts := range []int{3, 2} {
+		if err := UpdateTaskAttempts(path, 2, attempts); err != nil {
+			t.Fatalf("UpdateTaskAttempts() error = %v", err)
+		}
+		tasks, err := ReadTasks(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tasks[0].Attempts != 0 || tasks[1].Attempts != attempts {
+			t.Errorf("attempts = %d, %d; want 0, %d", tasks[0].Attempts, tasks[1].Attempts, attempts)
+		}
+	}
+	if err := UpdateTaskAttempts(path, 3, 1); err == nil {
+		t.Error("UpdateTaskAttempts() for a missing task: want error")
+	}
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -12,29 +14,37 @@ import (
 )
 
 // ExecuteCommand выполняет переданную команду.
-func ExecuteCommand(cmd domen.Command) (string, error) {
-	fmt.Println("Выбираем тип команды:")
-	switch cmd.Type {
-	case "создание":
+// Тип команды принимается как на русском, так и на английском (см. mapCommandType).
+// Отменённый ctx не даёт начать команду; компиляция прерывается отменой.
+func ExecuteCommand(ctx context.Context, cmd domen.Command) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	typ, ok := mapCommandType(cmd.Type)
+	if !ok {
+		return "", fmt.Errorf(i18n.T("неизвестный тип команды: %q"), cmd.Type)
+	}
+	switch typ {
+	case domen.CmdCreate:
 		return "", executeCreate(cmd)
-	case "удаление":
+	case domen.CmdDelete:
 		return "", executeDelete(cmd)
-	case "внесение изменений", "изменение", "edit", "изменить": // ← добавляем синонимы
+	case domen.CmdEdit:
 		return "", executeEdit(cmd)
-	case "добавление строк":
+	case domen.CmdAddLines:
 		return "", executeAddLines(cmd)
-	case "удаление строк":
+	case domen.CmdDeleteLines:
 		return "", executeDeleteLines(cmd)
-	case "копирование":
+	case domen.CmdCopy:
 		return "", executeCopy(cmd)
-	case "перемещение":
+	case domen.CmdMove:
 		return "", executeMove(cmd)
-	case "чтение":
+	case domen.CmdRead:
 		return executeRead(cmd)
-	case "компиляция":
-		return executeCompile(cmd)
+	case domen.CmdCompileCode:
+		return executeCompile(ctx, cmd)
 	default:
-		return "", fmt.Errorf("неизвестный тип команды: %q", cmd.Type)
+		return "", fmt.Errorf(i18n.T("неизвестный тип команды: %q"), cmd.Type)
 	}
 }
 
@@ -65,32 +75,30 @@ func writeLines(path string, lines []string) error {
 }
 
 func executeCreate(cmd domen.Command) error {
-	fmt.Println("Команда создания файла.")
 	if fileExists(cmd.Path) {
-		return fmt.Errorf("файл уже существует: %s", cmd.Path)
+		return fmt.Errorf(i18n.T("файл уже существует: %s"), cmd.Path)
 	}
 	if cmd.Content == "" {
-		return errors.New("пустое содержимое для создания файла")
+		return errors.New(i18n.T("пустое содержимое для создания файла"))
 	}
 	if dir := filepath.Dir(cmd.Path); dir != "." && dir != "" {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("не удалось создать директорию: %w", err)
+			return fmt.Errorf(i18n.T("не удалось создать директорию: %w"), err)
 		}
 	}
 	return os.WriteFile(cmd.Path, []byte(cmd.Content), 0644)
 }
 
 func executeDelete(cmd domen.Command) error {
-	fmt.Println("Команда удаления файла.")
 	if !fileExists(cmd.Path) {
-		return fmt.Errorf("файл не существует: %s", cmd.Path)
+		return fmt.Errorf(i18n.T("файл не существует: %s"), cmd.Path)
 	}
 	return os.Remove(cmd.Path)
 }
 
 func executeEdit(cmd domen.Command) error {
 	if !fileExists(cmd.Path) {
-		return fmt.Errorf("файл не существует: %s", cmd.Path)
+		return fmt.Errorf(i18n.T("файл не существует: %s"), cmd.Path)
 	}
 
 	// Если LLM прислал полный Content — просто перезаписываем файл (это самое надёжное)
@@ -100,21 +108,21 @@ func executeEdit(cmd domen.Command) error {
 
 	// Если Content пустой — работаем по старому режиму (точечное изменение строк)
 	if len(cmd.Lines) == 0 {
-		return errors.New("нет данных для изменения (ни Content, ни Lines)")
+		return errors.New(i18n.T("нет данных для изменения (ни Content, ни Lines)"))
 	}
 
 	lines, err := readLines(cmd.Path)
 	if err != nil {
-		return fmt.Errorf("не удалось прочитать файл %s: %w", cmd.Path, err)
+		return fmt.Errorf(i18n.T("не удалось прочитать файл %s: %w"), cmd.Path, err)
 	}
 
 	for lineNumStr, newText := range cmd.Lines {
 		lineNum, err := strconv.Atoi(lineNumStr)
 		if err != nil {
-			return fmt.Errorf("некорректный номер строки %q", lineNumStr)
+			return fmt.Errorf(i18n.T("некорректный номер строки %q"), lineNumStr)
 		}
 		if lineNum < 1 || lineNum > len(lines) {
-			return fmt.Errorf("строка %d не существует в файле %s", lineNum, cmd.Path)
+			return fmt.Errorf(i18n.T("строка %d не существует в файле %s"), lineNum, cmd.Path)
 		}
 		lines[lineNum-1] = newText
 	}
@@ -123,33 +131,32 @@ func executeEdit(cmd domen.Command) error {
 }
 
 func executeAddLines(cmd domen.Command) error {
-	fmt.Println("Команда добавления текста по линиям в файл.")
 	if !fileExists(cmd.Path) {
-		return fmt.Errorf("файл не существует: %s", cmd.Path)
+		return fmt.Errorf(i18n.T("файл не существует: %s"), cmd.Path)
 	}
 	if len(cmd.Lines) == 0 {
-		return errors.New("нет строк для добавления")
+		return errors.New(i18n.T("нет строк для добавления"))
 	}
 	lines, err := readLines(cmd.Path)
 	if err != nil {
-		return fmt.Errorf("не удалось прочитать файл %s: %w", cmd.Path, err)
+		return fmt.Errorf(i18n.T("не удалось прочитать файл %s: %w"), cmd.Path, err)
 	}
 	currentLen := len(lines)
 	keys := make([]int, 0, len(cmd.Lines))
 	for kStr := range cmd.Lines {
 		k, err := strconv.Atoi(kStr)
 		if err != nil {
-			return fmt.Errorf("некорректный номер строки для добавления %q: %w", kStr, err)
+			return fmt.Errorf(i18n.T("некорректный номер строки для добавления %q: %w"), kStr, err)
 		}
 		keys = append(keys, k)
 	}
 	sort.Ints(keys)
 	if len(keys) == 0 || keys[0] != currentLen+1 {
-		return fmt.Errorf("нельзя добавить строку %d: файл содержит только %d строк", keys[0], currentLen)
+		return fmt.Errorf(i18n.T("нельзя добавить строку %d: файл содержит только %d строк"), keys[0], currentLen)
 	}
 	for i := 1; i < len(keys); i++ {
 		if keys[i] != keys[i-1]+1 {
-			return errors.New("строки для добавления должны идти последовательно без пропусков")
+			return errors.New(i18n.T("строки для добавления должны идти последовательно без пропусков"))
 		}
 	}
 	for _, k := range keys {
@@ -159,29 +166,28 @@ func executeAddLines(cmd domen.Command) error {
 }
 
 func executeDeleteLines(cmd domen.Command) error {
-	fmt.Println("Команда удаления строк из файла.")
 	if !fileExists(cmd.Path) {
-		return fmt.Errorf("файл не существует: %s", cmd.Path)
+		return fmt.Errorf(i18n.T("файл не существует: %s"), cmd.Path)
 	}
 	if len(cmd.Lines) == 0 {
-		return errors.New("нет строк для удаления")
+		return errors.New(i18n.T("нет строк для удаления"))
 	}
 	lines, err := readLines(cmd.Path)
 	if err != nil {
-		return fmt.Errorf("не удалось прочитать файл %s: %w", cmd.Path, err)
+		return fmt.Errorf(i18n.T("не удалось прочитать файл %s: %w"), cmd.Path, err)
 	}
 	keys := make([]int, 0, len(cmd.Lines))
 	for kStr := range cmd.Lines {
 		k, err := strconv.Atoi(kStr)
 		if err != nil {
-			return fmt.Errorf("некорректный номер строки для удаления %q: %w", kStr, err)
+			return fmt.Errorf(i18n.T("некорректный номер строки для удаления %q: %w"), kStr, err)
 		}
 		keys = append(keys, k)
 	}
 	sort.Sort(sort.Reverse(sort.IntSlice(keys))) // удаляем с большей строки к меньшей
 	for _, k := range keys {
 		if k < 1 || k > len(lines) {
-			return fmt.Errorf("строка %d не существует в файле %s (всего строк: %d)", k, cmd.Path, len(lines))
+			return fmt.Errorf(i18n.T("строка %d не существует в файле %s (всего строк: %d)"), k, cmd.Path, len(lines))
 		}
 		idx := k - 1
 		lines = append(lines[:idx], lines[idx+1:]...)
@@ -190,63 +196,59 @@ func executeDeleteLines(cmd domen.Command) error {
 }
 
 func executeCopy(cmd domen.Command) error {
-	fmt.Println("Команда копирования файла.")
 	if cmd.SrcPath == "" || cmd.DstPath == "" {
-		return errors.New("не указаны пути для копирования")
+		return errors.New(i18n.T("не указаны пути для копирования"))
 	}
 	if !fileExists(cmd.SrcPath) {
-		return fmt.Errorf("исходный файл не существует: %s", cmd.SrcPath)
+		return fmt.Errorf(i18n.T("исходный файл не существует: %s"), cmd.SrcPath)
 	}
 	if fileExists(cmd.DstPath) {
-		return fmt.Errorf("целевой файл уже существует: %s", cmd.DstPath)
+		return fmt.Errorf(i18n.T("целевой файл уже существует: %s"), cmd.DstPath)
 	}
 	if dir := filepath.Dir(cmd.DstPath); dir != "." && dir != "" {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("не удалось создать директорию: %w", err)
+			return fmt.Errorf(i18n.T("не удалось создать директорию: %w"), err)
 		}
 	}
 	data, err := os.ReadFile(cmd.SrcPath)
 	if err != nil {
-		return fmt.Errorf("не удалось прочитать исходный файл: %w", err)
+		return fmt.Errorf(i18n.T("не удалось прочитать исходный файл: %w"), err)
 	}
 	return os.WriteFile(cmd.DstPath, data, 0644)
 }
 
 func executeMove(cmd domen.Command) error {
-	fmt.Println("Команда перемещения файла.")
 	if cmd.SrcPath == "" || cmd.DstPath == "" {
-		return errors.New("не указаны пути для перемещения")
+		return errors.New(i18n.T("не указаны пути для перемещения"))
 	}
 	if !fileExists(cmd.SrcPath) {
-		return fmt.Errorf("исходный файл не существует: %s", cmd.SrcPath)
+		return fmt.Errorf(i18n.T("исходный файл не существует: %s"), cmd.SrcPath)
 	}
 	if fileExists(cmd.DstPath) {
-		return fmt.Errorf("целевой файл уже существует: %s", cmd.DstPath)
+		return fmt.Errorf(i18n.T("целевой файл уже существует: %s"), cmd.DstPath)
 	}
 	if dir := filepath.Dir(cmd.DstPath); dir != "." && dir != "" {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("не удалось создать директорию: %w", err)
+			return fmt.Errorf(i18n.T("не удалось создать директорию: %w"), err)
 		}
 	}
 	return os.Rename(cmd.SrcPath, cmd.DstPath)
 }
 
 func executeRead(cmd domen.Command) (string, error) {
-	fmt.Println("Команда чтения из файла.")
 	if !fileExists(cmd.Path) {
-		return "", fmt.Errorf("файл не существует: %s", cmd.Path)
+		return "", fmt.Errorf(i18n.T("файл не существует: %s"), cmd.Path)
 	}
 	data, err := os.ReadFile(cmd.Path)
 	if err != nil {
-		return "", fmt.Errorf("не удалось прочитать файл %s: %w", cmd.Path, err)
+		return "", fmt.Errorf(i18n.T("не удалось прочитать файл %s: %w"), cmd.Path, err)
 	}
 	return string(data), nil
 }
 
-func executeCompile(cmd domen.Command) (string, error) {
-	fmt.Println("Команда компиляции файла.")
+func executeCompile(ctx context.Context, cmd domen.Command) (string, error) {
 	if !fileExists(cmd.Path) {
-		return "", fmt.Errorf("файл не существует: %s", cmd.Path)
+		return "", fmt.Errorf(i18n.T("файл не существует: %s"), cmd.Path)
 	}
-	return Compile(cmd.Path)
+	return Compile(ctx, cmd.Path)
 }
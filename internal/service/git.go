@@ -0,0 +1,376 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Режимы работы с git (domen.Config.GitMode).
+const (
+	GitModeOff    = "off"    // git не используется
+	GitModeCommit = "commit" // успешная задача коммитится в текущую ветку
+	GitModeBranch = "branch" // задача выполняется в ветке ralf/task-N (ralf/task-N-2…, если имя занято), ветка остаётся для ревью
+	GitModeMerge  = "merge"  // задача выполняется в ветке ralf/task-N, которая затем вливается в текущую
+)
+
+// maxSubjectRunes — длина заголовка коммита.
+const maxSubjectRunes = 72
+
+// ChangeSet — файлы, затронутые командами модели в рамках одной задачи,
+// и журнал их исходного содержимого: состояние файла запоминается при первом
+// обращении, до выполнения команды, и может быть восстановлено через Rollback.
+type ChangeSet struct {
+	mu    sync.Mutex
+	paths map[string]journalEntry // абсолютные пути
+}
+
+// journalEntry — исходное состояние файла. known = false, если его не удалось
+// прочитать (например, путь указывает на каталог): такой путь не восстанавливается.
+type journalEntry struct {
+	orig  fileState
+	known bool
+}
+
+// NewChangeSet создаёт пустой набор изменённых файлов.
+func NewChangeSet() *ChangeSet {
+	return &ChangeSet{paths: make(map[string]journalEntry)}
+}
+
+// Add запоминает затронутые пути и их текущее содержимое. Вызывается до
+// изменения файлов. Безопасен для nil-получателя.
+func (c *ChangeSet) Add(paths ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range paths {
+		if _, seen := c.paths[p]; p == "" || seen {
+			continue
+		}
+		state, err := readState(p)
+		c.paths[p] = journalEntry{orig: state, known: err == nil}
+	}
+}
+
+// Merge добавляет в набор пути из other вместе с их исходным состоянием;
+// уже известные пути не меняются. Безопасен для nil.
+func (c *ChangeSet) Merge(other *ChangeSet) {
+	if c == nil || other == nil {
+		return
+	}
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p, entry := range other.paths {
+		if _, seen := c.paths[p]; !seen {
+			c.paths[p] = entry
+		}
+	}
+}
+
+// Paths возвращает отсортированный список затронутых путей.
+func (c *ChangeSet) Paths() []string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paths := make([]string, 0, len(c.paths))
+	for p := range c.paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Diff возвращает изменения затронутых файлов относительно их исходного
+// состояния в формате unified diff; пути указываются относительно root.
+// Файлы без изменений пропускаются.
+func (c *ChangeSet) Diff(root string) string {
+	if c == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, p := range c.Paths() {
+		c.mu.Lock()
+		entry := c.paths[p]
+		c.mu.Unlock()
+		if !entry.known {
+			continue
+		}
+		cur, err := readState(p)
+		if err != nil || cur.equal(entry.orig) {
+			continue
+		}
+		name := p
+		if rel, err := filepath.Rel(root, p); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+		sb.WriteString(unifiedDiff(name, entry.orig, cur))
+	}
+	return sb.String()
+}
+
+// Rollback возвращает затронутые файлы к состоянию до первой команды:
+// изменённые восстанавливаются, созданные удаляются. Безопасен для nil-получателя.
+func (c *ChangeSet) Rollback() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for p, entry := range c.paths {
+		if !entry.known {
+			continue
+		}
+		if err := entry.orig.write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fileState — содержимое файла или его отсутствие.
+type fileState struct {
+	data   []byte
+	exists bool
+}
+
+func readState(path string) (fileState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{data: data, exists: true}, nil
+}
+
+func (s fileState) equal(o fileState) bool {
+	return s.exists == o.exists && bytes.Equal(s.data, o.data)
+}
+
+// write записывает состояние в path: создаёт файл или удаляет его.
+func (s fileState) write(path string) error {
+	if !s.exists {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, s.data, 0644)
+}
+
+// touchedPaths возвращает пути, которые команда может изменить.
+func touchedPaths(cmd domen.Command) []string {
+	typ, _ := mapCommandType(cmd.Type)
+	switch typ {
+	case domen.CmdCreate, domen.CmdDelete, domen.CmdEdit, domen.CmdAddLines, domen.CmdDeleteLines:
+		return []string{cmd.Path}
+	case domen.CmdCopy:
+		return []string{cmd.DstPath}
+	case domen.CmdMove:
+		return []string{cmd.SrcPath, cmd.DstPath}
+	}
+	return nil
+}
+
+// gitRepo выполняет команды git в каталоге проекта.
+type gitRepo struct {
+	dir string
+}
+
+// run запускает git и возвращает stdout без завершающих пробелов.
+func (g gitRepo) run(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// rel переводит абсолютные пути в пути относительно каталога репозитория.
+func (g gitRepo) rel(paths []string) []string {
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if r, err := filepath.Rel(g.dir, p); err == nil {
+			p = r
+		}
+		result = append(result, filepath.ToSlash(p))
+	}
+	return result
+}
+
+// hasBranch сообщает, есть ли локальная ветка name.
+func (g gitRepo) hasBranch(name string) bool {
+	_, err := g.run("rev-parse", "--verify", "--quiet", "refs/heads/"+name)
+	return err == nil
+}
+
+// taskGit — состояние git на время одной задачи.
+type taskGit struct {
+	repo   gitRepo
+	mode   string
+	origin string // ветка, с которой началась задача
+	branch string // ветка задачи (в режимах branch и merge)
+	log    *slog.Logger
+}
+
+// startTaskGit проверяет, что проект — git-репозиторий с коммитами, и, если
+// нужно, создаёт ветку задачи.
+// В режиме off возвращает nil: все методы taskGit безопасны для nil.
+func startTaskGit(ws Workspace, mode string, task domen.Task) (*taskGit, error) {
+	switch mode {
+	case "", GitModeOff:
+		return nil, nil
+	case GitModeCommit, GitModeBranch, GitModeMerge:
+	default:
+		return nil, fmt.Errorf(i18n.T("неизвестный режим git: %q (допустимо: off, commit, branch, merge)"), mode)
+	}
+
+	g := &taskGit{repo: gitRepo{dir: ws.Root}, mode: mode, log: slog.Default().With(logging.KeyTask, task.Num)}
+	if _, err := g.repo.run("rev-parse", "--verify", "HEAD"); err != nil {
+		return nil, fmt.Errorf(i18n.T("каталог проекта не является git-репозиторием с коммитами: %w"), err)
+	}
+	if mode == GitModeCommit {
+		return g, nil
+	}
+
+	var err error
+	if g.origin, err = g.repo.run("symbolic-ref", "--short", "HEAD"); err != nil {
+		return nil, fmt.Errorf(i18n.T("для работы в ветках нужна текущая ветка (сейчас detached HEAD): %w"), err)
+	}
+	// ветка прошлого запуска остаётся для ревью — новая получает свободное имя
+	g.branch = fmt.Sprintf("ralf/task-%d", task.Num)
+	for n := 2; g.repo.hasBranch(g.branch); n++ {
+		g.branch = fmt.Sprintf("ralf/task-%d-%d", task.Num, n)
+	}
+	if _, err := g.repo.run("switch", "-c", g.branch); err != nil {
+		return nil, fmt.Errorf(i18n.T("не удалось создать ветку задачи %s: %w"), g.branch, err)
+	}
+	return g, nil
+}
+
+// finish завершает задачу в git: при успехе коммитит затронутые файлы
+// (и вливает ветку в режиме merge), при ошибке откатывает их по журналу changes.
+// Возвращает итоговую ошибку задачи.
+func (g *taskGit) finish(task domen.Task, changes *ChangeSet, taskErr error) error {
+	if g == nil {
+		return taskErr
+	}
+	paths := g.repo.rel(changes.Paths())
+	if taskErr != nil {
+		if err := g.rollback(changes, paths); err != nil {
+			return errors.Join(taskErr, fmt.Errorf(i18n.T("не удалось откатить изменения задачи: %w"), err))
+		}
+		return taskErr
+	}
+	if err := g.commit(paths, commitMessage(task)); err != nil {
+		return fmt.Errorf(i18n.T("не удалось закоммитить задачу: %w"), err)
+	}
+	return nil
+}
+
+// commit коммитит ровно затронутые задачей файлы.
+func (g *taskGit) commit(paths []string, message string) error {
+	var existing []string
+	for _, p := range paths {
+		if _, err := os.Stat(filepath.Join(g.repo.dir, p)); err == nil || g.inIndex(p) {
+			existing = append(existing, p)
+		}
+	}
+	if len(existing) > 0 {
+		if _, err := g.repo.run(append([]string{"add", "-A", "--"}, existing...)...); err != nil {
+			return err
+		}
+		// Нет изменений относительно HEAD — коммитить нечего
+		if _, err := g.repo.run(append([]string{"diff", "--cached", "--quiet", "--"}, existing...)...); err != nil {
+			if _, err := g.repo.run(append([]string{"commit", "-m", message, "--only", "--"}, existing...)...); err != nil {
+				return err
+			}
+			g.log.Info(i18n.T("Изменения задачи закоммичены"), "files", existing)
+		}
+	}
+
+	switch g.mode {
+	case GitModeBranch:
+		// ветка остаётся для ревью, работа продолжается в исходной ветке
+		if _, err := g.repo.run("switch", g.origin); err != nil {
+			return err
+		}
+		g.log.Info(i18n.T("Ветка задачи оставлена для ревью"), "branch", g.branch)
+	case GitModeMerge:
+		if _, err := g.repo.run("switch", g.origin); err != nil {
+			return err
+		}
+		if _, err := g.repo.run("merge", "--no-ff", "-m", message, g.branch); err != nil {
+			_, _ = g.repo.run("merge", "--abort")
+			return fmt.Errorf(i18n.T("не удалось влить ветку %s: %w"), g.branch, err)
+		}
+		if _, err := g.repo.run("branch", "-d", g.branch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inIndex сообщает, отслеживается ли файл git.
+func (g *taskGit) inIndex(path string) bool {
+	_, err := g.repo.run("ls-files", "--error-unmatch", "--", path)
+	return err == nil
+}
+
+// rollback возвращает затронутые задачей файлы к состоянию до задачи по журналу
+// changes, а не к исходному коммиту: незакоммиченные правки пользователя в этих
+// файлах сохраняются. Остальные изменения рабочего дерева не трогаются.
+// Ветка задачи удаляется.
+func (g *taskGit) rollback(changes *ChangeSet, paths []string) error {
+	var errs []error
+	if err := changes.Rollback(); err != nil {
+		errs = append(errs, err)
+	}
+	if g.branch != "" {
+		if _, err := g.repo.run("switch", g.origin); err != nil {
+			errs = append(errs, err)
+		} else if _, err := g.repo.run("branch", "-D", g.branch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		g.log.Info(i18n.T("Изменения задачи откачены"), "files", paths)
+	}
+	return errors.Join(errs...)
+}
+
+// commitMessage формирует сообщение коммита из номера и описания задачи.
+func commitMessage(task domen.Task) string {
+	description := strings.Join(strings.Fields(task.Description), " ")
+	subject := fmt.Sprintf(i18n.T("Задача %d: %s"), task.Num, description)
+	if utf8.RuneCountInString(subject) <= maxSubjectRunes {
+		return subject
+	}
+	return string([]rune(subject)[:maxSubjectRunes-1]) + "…\n\n" + description
+}
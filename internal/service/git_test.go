@@ -0,0 +1,214 @@
+package service
+
+import (
+	"Ralf/domen"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newGitProject создаёт репозиторий с одним коммитом (main.go) и рабочее пространство в нём.
+func newGitProject(t *testing.T) (Workspace, gitRepo) {
+	t.Helper()
+	root := t.TempDir()
+	repo := gitRepo{dir: root}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@example.com"},
+	} {
+		if _, err := repo.run(args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(root, "prog", "main.go"), "package main\n")
+	writeFile(t, filepath.Join(root, "notes.txt"), "notes\n")
+	if _, err := repo.run("add", "."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.run("commit", "-q", "-m", "initial"); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := NewWorkspace(domen.Config{WorkingDir: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Changes = NewChangeSet()
+	return ws, repo
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// applyTaskChanges имитирует работу модели: правит main.go и создаёт новый файл,
+// а пользователь параллельно правит notes.txt.
+func applyTaskChanges(t *testing.T, ws Workspace) {
+	t.Helper()
+	for _, cmd := range []domen.Command{
+		{Type: "edit", Path: "prog/main.go", Content: "package main\n\nfunc main() {}\n"},
+		{Type: "create", Path: "prog/add.go", Content: "package main\n\nfunc Add(a, b int) int { return a + b }\n"},
+	} {
+		if _, err := ws.Execute(t.Context(), cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(ws.Root, "notes.txt"), "user edit\n")
+}
+
+func TestTaskGit_commit(t *testing.T) {
+	ws, repo := newGitProject(t)
+	task := domen.Task{Num: 7, Description: "Реализуйте функцию сложения."}
+	g, err := startTaskGit(ws, GitModeCommit, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyTaskChanges(t, ws)
+
+	if err := g.finish(task, ws.Changes, nil); err != nil {
+		t.Fatalf("finish() error = %v", err)
+	}
+	files, _ := repo.run("show", "--name-only", "--format=%s", "HEAD")
+	if files != "Задача 7: Реализуйте функцию сложения.\n\nprog/add.go\nprog/main.go" {
+		t.Errorf("HEAD commit = %q", files)
+	}
+	if status, _ := repo.run("status", "--porcelain"); status != "M notes.txt" {
+		t.Errorf("status after commit = %q, want only the user's notes.txt change", status)
+	}
+}
+
+func TestTaskGit_rollback(t *testing.T) {
+	ws, repo := newGitProject(t)
+	// незакоммиченная правка пользователя в файле, который затем правит задача
+	userMain := "package main\n\n// правка пользователя\n"
+	writeFile(t, filepath.Join(ws.Root, "prog", "main.go"), userMain)
+	task := domen.Task{Num: 1, Description: "задача"}
+	g, err := startTaskGit(ws, GitModeCommit, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyTaskChanges(t, ws)
+
+	taskErr := errors.New("compile failed")
+	if err := g.finish(task, ws.Changes, taskErr); !errors.Is(err, taskErr) {
+		t.Fatalf("finish() error = %v, want %v", err, taskErr)
+	}
+	if status, _ := repo.run("status", "--porcelain"); status != "M notes.txt\n M prog/main.go" {
+		t.Errorf("status after rollback = %q, want only the user's changes", status)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws.Root, "prog", "main.go")); string(data) != userMain {
+		t.Errorf("prog/main.go after rollback = %q, want the user's uncommitted edit", data)
+	}
+	if _, err := os.Stat(filepath.Join(ws.Root, "prog", "add.go")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("new file is not removed: %v", err)
+	}
+	if count, _ := repo.run("rev-list", "--count", "HEAD"); count != "1" {
+		t.Errorf("commits after rollback = %s, want 1", count)
+	}
+}
+
+func TestTaskGit_branches(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		wantBranch bool // ветка задачи осталась
+		wantInMain bool // изменения есть в main
+	}{
+		{name: "branch", mode: GitModeBranch, wantBranch: true},
+		{name: "merge", mode: GitModeMerge, wantInMain: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, repo := newGitProject(t)
+			// ветка той же задачи, оставшаяся от прошлого запуска
+			if _, err := repo.run("branch", "ralf/task-3"); err != nil {
+				t.Fatal(err)
+			}
+			task := domen.Task{Num: 3, Description: "сложение"}
+			g, err := startTaskGit(ws, tt.mode, task)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if branch, _ := repo.run("symbolic-ref", "--short", "HEAD"); branch != "ralf/task-3-2" {
+				t.Fatalf("current branch = %q, want ralf/task-3-2", branch)
+			}
+			applyTaskChanges(t, ws)
+
+			if err := g.finish(task, ws.Changes, nil); err != nil {
+				t.Fatalf("finish() error = %v", err)
+			}
+			if branch, _ := repo.run("symbolic-ref", "--short", "HEAD"); branch != "main" {
+				t.Errorf("current branch = %q, want main", branch)
+			}
+			_, err = repo.run("rev-parse", "--verify", "ralf/task-3-2")
+			if (err == nil) != tt.wantBranch {
+				t.Errorf("task branch exists = %v, want %v", err == nil, tt.wantBranch)
+			}
+			_, err = repo.run("cat-file", "-e", "main:prog/add.go")
+			if (err == nil) != tt.wantInMain {
+				t.Errorf("prog/add.go in main = %v, want %v", err == nil, tt.wantInMain)
+			}
+		})
+	}
+}
+
+func TestTaskGit_off(t *testing.T) {
+	g, err := startTaskGit(Workspace{Root: t.TempDir()}, GitModeOff, domen.Task{})
+	if err != nil || g != nil {
+		t.Fatalf("startTaskGit(off) = %v, %v; want nil, nil", g, err)
+	}
+	taskErr := errors.New("fail")
+	if err := g.finish(domen.Task{}, nil, taskErr); err != taskErr {
+		t.Errorf("nil finish() = %v, want the task error", err)
+	}
+	if _, err := startTaskGit(Workspace{Root: t.TempDir()}, GitModeCommit, domen.Task{}); err == nil {
+		t.Errorf("startTaskGit() outside a repository: want error")
+	}
+}
+
+func Test_commitMessage(t *testing.T) {
+	short := commitMessage(domen.Task{Num: 2, Description: "Сумма\n  двух чисел."})
+	if short != "Задача 2: Сумма двух чисел." {
+		t.Errorf("commitMessage() = %q", short)
+	}
+	long := commitMessage(domen.Task{Num: 2, Description: strings.Repeat("длинное описание ", 10)})
+	subject, body, ok := strings.Cut(long, "\n\n")
+	if !ok || len([]rune(subject)) != maxSubjectRunes || !strings.HasPrefix(body, "длинное описание") {
+		t.Errorf("commitMessage() = %q", long)
+	}
+}
+
+func TestChangeSet_Rollback(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "sub", "new.go")
+	writeFile(t, existing, "package main\n")
+
+	changes := NewChangeSet()
+	changes.Add(existing, created)
+	changes.Add(existing) // повторное обращение не перезаписывает исходное состояние
+	writeFile(t, existing, "package broken\n")
+	writeFile(t, created, "package sub\n")
+	changes.Add(existing)
+
+	if err := changes.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "package main\n" {
+		t.Errorf("existing file = %q, want original content", data)
+	}
+	if _, err := os.Stat(created); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("created file is not removed: %v", err)
+	}
+	if err := (*ChangeSet)(nil).Rollback(); err != nil {
+		t.Errorf("nil Rollback() = %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"Ralf/internal/i18n"
+	"bytes"
+	"context"
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Режимы обработки записанных .go-файлов (domen.Config.Format).
+const (
+	FormatOff     = "off"     // файлы остаются как их прислала модель
+	FormatOn      = "on"      // файл форматируется go/format
+	FormatImports = "imports" // форматирование и исправление импортов, как у goimports
+)
+
+// CheckFormat — имя проверки в замечаниях форматирования о синтаксисе файла.
+const CheckFormat = "format"
+
+// importIndex — пакеты, которые можно добавить в импорты: имя пакета → пути.
+type importIndex map[string][]string
+
+// stdPackages — пакеты стандартной библиотеки, кроме внутренних; список
+// получается один раз за запуск.
+var stdPackages = sync.OnceValue(func() importIndex {
+	return listPackages(context.Background(), "", "std", false)
+})
+
+// listPackages возвращает пакеты по шаблону pattern (go list в каталоге dir).
+// Пакеты main пропускаются, внутренние — если не allowInternal. Ошибка go list
+// даёт пустой список: исправление импортов — лишь подсказка.
+func listPackages(ctx context.Context, dir, pattern string, allowInternal bool) importIndex {
+	index := make(importIndex)
+	cmd := exec.CommandContext(ctx, "go", "list", "-e", "-f", "{{.Name}} {{.ImportPath}}", pattern)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return index
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		name, importPath, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || name == "" || name == "main" {
+			continue
+		}
+		if !allowInternal && (strings.HasPrefix(importPath, "vendor/") || slices.Contains(strings.Split(importPath, "/"), "internal")) {
+			continue
+		}
+		index[name] = append(index[name], importPath)
+	}
+	return index
+}
+
+// formatFiles обрабатывает .go-файлы, записанные командами модели, по режиму
+// w.Format. Список пакетов для исправления импортов собирается не больше
+// одного раза за вызов. Файл, который не разбирается, не меняется: его
+// синтаксические ошибки возвращаются как замечания.
+func (w Workspace) formatFiles(ctx context.Context, paths []string) []Diagnostic {
+	if w.Format == "" || w.Format == FormatOff {
+		return nil
+	}
+	var index func() importIndex
+	if w.Format == FormatImports {
+		index = sync.OnceValue(func() importIndex {
+			merged := make(importIndex)
+			for _, idx := range []importIndex{stdPackages(), listPackages(ctx, w.Root, "./...", true)} {
+				for name, paths := range idx {
+					merged[name] = append(merged[name], paths...)
+				}
+			}
+			return merged
+		})
+	}
+	var diags []Diagnostic
+	for _, absPath := range paths {
+		if !strings.HasSuffix(absPath, ".go") {
+			continue
+		}
+		rel := w.Rel([]string{absPath})[0]
+		err := formatGoFile(absPath, index)
+		if found := syntaxDiagnostics(CheckFormat, rel, err); len(found) > 0 {
+			w.logger().Warn(i18n.T("Файл не разбирается, форматирование пропущено"), "path", rel, "error", err)
+			diags = append(diags, found...)
+		} else if err != nil {
+			w.logger().Warn(i18n.T("Не удалось отформатировать файл"), "path", rel, "error", err)
+		}
+	}
+	return diags
+}
+
+// syntaxDiagnostics переводит синтаксические ошибки go/parser в замечания
+// для файла rel. Для других ошибок возвращает nil.
+func syntaxDiagnostics(check, rel string, err error) []Diagnostic {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return nil
+	}
+	diags := make([]Diagnostic, 0, len(list))
+	for _, e := range list {
+		diags = append(diags, Diagnostic{Check: check, File: rel, Line: e.Pos.Line, Col: e.Pos.Column, Message: e.Msg})
+	}
+	return diags
+}
+
+// formatGoFile форматирует файл filename как gofmt. Если index не nil, сначала
+// исправляются импорты: неиспользуемые пакеты из index удаляются, а недостающие
+// добавляются, когда имя однозначно указывает на пакет из index. Файл
+// перезаписывается, только если изменился. Синтаксическая ошибка возвращается
+// как scanner.ErrorList.
+func formatGoFile(filename string, index func() importIndex) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	if index != nil {
+		fixImports(fset, file, index(), siblingDecls(filename))
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return err
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(src, formatted) {
+		return nil
+	}
+	return os.WriteFile(filename, formatted, 0644)
+}
+
+// fixImports удаляет неиспользуемые импорты известных пакетов и добавляет
+// недостающие. declared — имена верхнего уровня из других файлов пакета:
+// обращение к ним — не обращение к пакету.
+func fixImports(fset *token.FileSet, file *ast.File, index importIndex, declared map[string]bool) {
+	used := usedPackages(file)
+	names := make(map[string]string) // путь → имя пакета
+	for name, paths := range index {
+		for _, p := range paths {
+			names[p] = name
+		}
+	}
+
+	imported := make(map[string]bool)
+	decls := file.Decls[:0]
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+		specs := gen.Specs[:0]
+		for _, s := range gen.Specs {
+			spec := s.(*ast.ImportSpec)
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			name, known := names[importPath]
+			if spec.Name != nil {
+				name, known = spec.Name.Name, true
+			}
+			// неизвестные пакеты и импорты ради побочных эффектов не трогаем
+			if !known || name == "_" || name == "." || used[name] {
+				imported[name] = true
+				if !known {
+					imported[path.Base(importPath)] = true
+				}
+				specs = append(specs, spec)
+			}
+		}
+		gen.Specs = specs
+		if len(specs) > 0 {
+			decls = append(decls, gen)
+		}
+	}
+	file.Decls = decls
+
+	var missing []string
+	for name := range used {
+		if imported[name] || declared[name] || len(index[name]) != 1 {
+			continue
+		}
+		missing = append(missing, index[name][0])
+	}
+	if len(missing) == 0 {
+		file.Imports = collectImports(file)
+		return
+	}
+
+	var gen *ast.GenDecl
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			gen = d
+			break
+		}
+	}
+	if gen == nil {
+		gen = &ast.GenDecl{Tok: token.IMPORT}
+		file.Decls = append([]ast.Decl{gen}, file.Decls...)
+	}
+	if !gen.Lparen.IsValid() && len(gen.Specs)+len(missing) > 1 {
+		// без скобок в объявлении помещается только один импорт
+		gen.Lparen = file.Name.End()
+		gen.Rparen = file.Name.End()
+	}
+	for _, p := range missing {
+		gen.Specs = append(gen.Specs, &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(p)}})
+	}
+	file.Imports = collectImports(file)
+	ast.SortImports(fset, file)
+}
+
+// collectImports возвращает импорты файла после правки объявлений.
+func collectImports(file *ast.File) []*ast.ImportSpec {
+	var specs []*ast.ImportSpec
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			for _, s := range gen.Specs {
+				specs = append(specs, s.(*ast.ImportSpec))
+			}
+		}
+	}
+	return specs
+}
+
+// usedPackages возвращает имена, через которые файл обращается к другим
+// пакетам: левые части селекторов, не объявленные в самом файле.
+func usedPackages(file *ast.File) map[string]bool {
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		// без проверки типов хватает разрешения имён парсера: у ссылки
+		// на импорт нет объекта в области видимости файла
+		if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+			used[id.Name] = true
+		}
+		return true
+	})
+	return used
+}
+
+// siblingDecls собирает имена верхнего уровня из остальных .go-файлов
+// каталога filename.
+func siblingDecls(filename string) map[string]bool {
+	declared := make(map[string]bool)
+	dir := filepath.Dir(filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return declared
+	}
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		other := filepath.Join(dir, e.Name())
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") || other == filename {
+			continue
+		}
+		file, err := parser.ParseFile(fset, other, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					declared[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							declared[n.Name] = true
+						}
+					case *ast.TypeSpec:
+						declared[s.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return declared
+}
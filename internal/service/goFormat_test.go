@@ -0,0 +1,144 @@
+package service
+
+import (
+	"Ralf/domen"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_formatGoFile(t *testing.T) {
+	index := importIndex{
+		"fmt":     {"fmt"},
+		"os":      {"os"},
+		"strings": {"strings"},
+		"calc":    {"sandbox/calc"},
+		"rand":    {"math/rand", "crypto/rand"},
+	}
+	tests := []struct {
+		name    string
+		src     string
+		sibling string // другой файл того же пакета
+		imports bool
+		want    string
+	}{
+		{
+			name: "format only",
+			src:  "package p\nfunc F( ) int {\nreturn 1\n}\n",
+			want: "package p\n\nfunc F() int {\n\treturn 1\n}\n",
+		},
+		{
+			name:    "unused removed and missing added",
+			src:     "package p\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n\nfunc F() string { return strings.TrimSpace(fmt.Sprint(calc.Add(1, 2))) }\n",
+			imports: true,
+			want:    "package p\n\nimport (\n\t\"fmt\"\n\t\"sandbox/calc\"\n\t\"strings\"\n)\n\nfunc F() string { return strings.TrimSpace(fmt.Sprint(calc.Add(1, 2))) }\n",
+		},
+		{
+			name:    "import added without an import block",
+			src:     "package p\n\nfunc F() string { return strings.ToUpper(\"a\") }\n",
+			imports: true,
+			want:    "package p\n\nimport \"strings\"\n\nfunc F() string { return strings.ToUpper(\"a\") }\n",
+		},
+		{
+			name:    "unknown, ambiguous and local names are left alone",
+			src:     "package p\n\nimport \"example.com/lib\"\n\nfunc F() int { os := cfg{}; _ = rand.Int(); return os.n + calc.n }\n",
+			sibling: "package p\n\ntype cfg struct{ n int }\n\nvar calc cfg\n",
+			imports: true,
+			want:    "package p\n\nimport \"example.com/lib\"\n\nfunc F() int { os := cfg{}; _ = rand.Int(); return os.n + calc.n }\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "p.go")
+			if err := os.WriteFile(path, []byte(tt.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.sibling != "" {
+				if err := os.WriteFile(filepath.Join(dir, "q.go"), []byte(tt.sibling), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var idx func() importIndex
+			if tt.imports {
+				idx = func() importIndex { return index }
+			}
+			if err := formatGoFile(path, idx); err != nil {
+				t.Fatalf("formatGoFile() error = %v", err)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.want {
+				t.Errorf("formatGoFile() wrote\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_formatGoFile_syntaxError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.go")
+	src := "package p\n\nfunc F() {\n\treturn 1 +\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diags := syntaxDiagnostics("format", "p.go", formatGoFile(path, nil))
+	if len(diags) == 0 || diags[0].File != "p.go" || diags[0].Line != 5 {
+		t.Errorf("syntaxDiagnostics() = %+v, want a finding at p.go:5", diags)
+	}
+	if got, _ := os.ReadFile(path); string(got) != src {
+		t.Errorf("file with a syntax error was rewritten:\n%s", got)
+	}
+}
+
+func TestWorkspace_ExecuteAll_format(t *testing.T) {
+	newSandbox(t)
+	ws, err := NewWorkspace(domen.Config{Format: FormatImports})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "package main\nimport \"os\"\nfunc Greeting(name string) string {\n  return strings.TrimSpace(\"Hello, \" + name)\n}\n"
+	// номер строки в add_lines считается по файлу в том виде, в каком его прислала модель
+	errs, format := ws.ExecuteAll(t.Context(), []domen.Command{
+		{Type: "create", Path: "prog/main.go", Content: content},
+		{Type: "add_lines", Path: "prog/main.go", Lines: map[string]string{"6": "func main() {}"}},
+	})
+	if len(errs) > 0 || len(format) > 0 {
+		t.Fatalf("ExecuteAll() = %v, %v", errs, format)
+	}
+	got, err := os.ReadFile("prog/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"import \"strings\"\n", "\n\treturn", "func main() {}"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("prog/main.go =\n%s\nwant %q", got, want)
+		}
+	}
+	if strings.Contains(string(got), "\"os\"") {
+		t.Errorf("prog/main.go =\n%s\nstill imports os", got)
+	}
+}
+
+func TestLLMClient_applyCommands_formatSyntax(t *testing.T) {
+	newSandbox(t)
+	ws, err := NewWorkspace(domen.Config{Format: FormatOn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := NewTaskReport(domen.Task{})
+	client := &LLMClient{Workspace: ws, Report: report}
+	var syntax strings.Builder
+	errs := client.applyCommands(t.Context(), []domen.Command{
+		{Type: "create", Path: "prog/main.go", Content: goodMainCode},
+		{Type: "add_lines", Path: "prog/main.go", Lines: map[string]string{"11": "func broken( {"}},
+	}, &syntax)
+	if len(errs) > 0 {
+		t.Fatalf("applyCommands() errors = %v", errs)
+	}
+	if !strings.Contains(syntax.String(), "prog/main.go:") {
+		t.Errorf("syntax = %q, want a formatter finding for prog/main.go", syntax.String())
+	}
+	if len(report.Findings) != 1 || !strings.HasPrefix(report.Findings[0], "prog/main.go:") {
+		t.Errorf("report findings = %q", report.Findings)
+	}
+}
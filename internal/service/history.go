@@ -0,0 +1,66 @@
+package service
+
+// TaskHistory хранит переписку с LLM в рамках одной задачи:
+// исходную задачу, каждую пачку команд от модели и каждую диагностику.
+// История отправляется при каждой попытке исправления, чтобы модель
+// видела, какие исправления уже были и не сработали.
+type TaskHistory struct {
+	messages []Message
+}
+
+// NewTaskHistory создаёт пустую историю задачи.
+func NewTaskHistory() *TaskHistory {
+	return &TaskHistory{}
+}
+
+// AddPrompt добавляет запрос пользователя (текст задачи или запрос тестов).
+func (h *TaskHistory) AddPrompt(prompt string) {
+	h.add("user", prompt)
+}
+
+// AddCommands добавляет ответ модели — пачку команд в исходном виде.
+func (h *TaskHistory) AddCommands(response string) {
+	h.add("assistant", response)
+}
+
+// AddDiagnostic добавляет диагностику (лог компиляции, ошибку выполнения команд).
+func (h *TaskHistory) AddDiagnostic(diagnostic string) {
+	h.add("user", diagnostic)
+}
+
+// Len возвращает количество сообщений в истории.
+func (h *TaskHistory) Len() int {
+	if h == nil {
+		return 0
+	}
+	return len(h.messages)
+}
+
+// Messages возвращает историю, урезанную до budget токенов по оценке counter.
+// Первое сообщение (исходная задача) сохраняется всегда, затем
+// отбрасываются самые старые пары «ответ модели — диагностика»,
+// чтобы чередование ролей не нарушалось.
+func (h *TaskHistory) Messages(budget int, counter TokenCounter) []Message {
+	if h.Len() == 0 {
+		return nil
+	}
+	first := h.messages[0]
+	rest := h.messages[1:]
+	for len(rest) > 0 && countMessagesTokens(counter, h.messages[:1])+countMessagesTokens(counter, rest) > budget {
+		if len(rest) >= 2 {
+			rest = rest[2:]
+		} else {
+			rest = rest[1:]
+		}
+	}
+	result := make([]Message, 0, len(rest)+1)
+	result = append(result, first)
+	return append(result, rest...)
+}
+
+func (h *TaskHistory) add(role, content string) {
+	if h == nil {
+		return
+	}
+	h.messages = append(h.messages, Message{Role: role, Content: content})
+}
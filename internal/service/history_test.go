@@ -0,0 +1,77 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+// testCounter считает один символ за один токен, чтобы бюджеты в тестах были наглядными.
+var testCounter = HeuristicCounter{CharsPerToken: 1}
+
+func TestTaskHistory_Messages(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    int
+		wantRoles []string
+		wantFirst string
+		wantLast  string
+	}{
+		{
+			name:      "all messages fit",
+			budget:    1000,
+			wantRoles: []string{"user", "assistant", "user", "assistant", "user", "assistant"},
+			wantFirst: "задача",
+			wantLast:  "исправление 2",
+		},
+		{
+			name: "oldest pair dropped",
+			budget: countMessagesTokens(testCounter, []Message{
+				{Content: "задача"}, {Content: "исправление 1"}, {Content: strings.Repeat("x", 20)}, {Content: "исправление 2"},
+			}),
+			wantRoles: []string{"user", "assistant", "user", "assistant"},
+			wantFirst: "задача",
+			wantLast:  "исправление 2",
+		},
+		{
+			name:      "only task left",
+			budget:    1,
+			wantRoles: []string{"user"},
+			wantFirst: "задача",
+			wantLast:  "задача",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewTaskHistory()
+			h.AddPrompt("задача")
+			h.AddCommands("команды")
+			h.AddDiagnostic(strings.Repeat("x", 20))
+			h.AddCommands("исправление 1")
+			h.AddDiagnostic(strings.Repeat("x", 20))
+			h.AddCommands("исправление 2")
+
+			got := h.Messages(tt.budget, testCounter)
+			roles := make([]string, 0, len(got))
+			for _, m := range got {
+				roles = append(roles, m.Role)
+			}
+			if strings.Join(roles, ",") != strings.Join(tt.wantRoles, ",") {
+				t.Errorf("Messages() roles = %v, want %v", roles, tt.wantRoles)
+			}
+			if got[0].Content != tt.wantFirst {
+				t.Errorf("Messages() first = %q, want %q", got[0].Content, tt.wantFirst)
+			}
+			if got[len(got)-1].Content != tt.wantLast {
+				t.Errorf("Messages() last = %q, want %q", got[len(got)-1].Content, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestTaskHistory_Nil(t *testing.T) {
+	var h *TaskHistory
+	h.AddPrompt("задача")
+	if got := h.Messages(1000, testCounter); got != nil {
+		t.Errorf("Messages() = %v, want nil", got)
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Политики проверок качества (domen.Config.Lint).
+const (
+	LintFix    = "fix"    // замечания отправляются модели на исправление
+	LintReport = "report" // замечания только пишутся в журнал и отчёт
+)
+
+// Встроенные проверки качества; любое другое имя — внешний анализатор
+// из PATH (например, staticcheck), который запускается с шаблоном пакетов.
+const (
+	CheckGofmt = "gofmt" // файлы, не отформатированные gofmt
+	CheckVet   = "vet"   // go vet
+)
+
+// errLintFindings — проверки с политикой fix нашли замечания.
+var errLintFindings = i18n.Error("найдены замечания анализаторов")
+
+// LintCheck — проверка качества и политика для её замечаний.
+type LintCheck struct {
+	Name   string
+	Policy string
+}
+
+// ParseLintChecks разбирает список проверок через запятую: «имя» или
+// «имя:политика», где политика — fix (по умолчанию) или report.
+func ParseLintChecks(list string) ([]LintCheck, error) {
+	var checks []LintCheck
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, policy, _ := strings.Cut(item, ":")
+		check := LintCheck{Name: strings.TrimSpace(name), Policy: strings.TrimSpace(policy)}
+		if check.Policy == "" {
+			check.Policy = LintFix
+		}
+		if check.Policy != LintFix && check.Policy != LintReport {
+			return nil, fmt.Errorf(i18n.T("проверка %s: неизвестная политика %q (нужно fix или report)"), check.Name, check.Policy)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// Diagnostic — замечание анализатора.
+type Diagnostic struct {
+	Check   string // имя проверки
+	File    string // путь относительно корня проекта
+	Line    int    // 0 — замечание ко всему файлу
+	Col     int
+	Message string
+}
+
+// String возвращает замечание в привычном для Go виде file:line:col: message.
+func (d Diagnostic) String() string {
+	var sb strings.Builder
+	if d.File != "" {
+		sb.WriteString(d.File)
+		if d.Line > 0 {
+			fmt.Fprintf(&sb, ":%d", d.Line)
+			if d.Col > 0 {
+				fmt.Fprintf(&sb, ":%d", d.Col)
+			}
+		}
+		sb.WriteString(": ")
+	}
+	fmt.Fprintf(&sb, "%s (%s)", d.Message, d.Check)
+	return sb.String()
+}
+
+// lintStage доводит код до чистого результата проверок с политикой fix через
+// fixLoop, затем один раз запускает проверки с политикой report и сохраняет их
+// замечания в отчёт задачи. Исправления проверяются и на сборку тестов.
+func lintStage(ctx context.Context, client *LLMClient, history *TaskHistory, checks []LintCheck, maxAttempts int) error {
+	ws := client.Workspace
+	var fix, report []string
+	for _, check := range checks {
+		if check.Policy == LintFix {
+			fix = append(fix, check.Name)
+		} else {
+			report = append(report, check.Name)
+		}
+	}
+
+	lint := func(ctx context.Context) (string, error) {
+		if compileLog, err := ws.CompileTests(ctx); err != nil {
+			return compileLog, err
+		}
+		diags, err := ws.Lint(ctx, fix...)
+		if err != nil {
+			return err.Error(), err
+		}
+		if len(diags) == 0 {
+			return "", nil
+		}
+		lines := make([]string, 0, len(diags))
+		for _, d := range diags {
+			lines = append(lines, d.String())
+		}
+		return strings.Join(lines, "\n"), errLintFindings
+	}
+	if err := fixLoop(ctx, client, history, StageLint, lint, ws.Path("main.go"), maxAttempts, ""); err != nil {
+		return err
+	}
+
+	diags, err := ws.Lint(ctx, report...)
+	if err != nil {
+		return err
+	}
+	for _, d := range diags {
+		client.Log.Warn(i18n.T("Замечание анализатора"), "check", d.Check, logging.KeyPath, d.File, "line", d.Line, "message", d.Message)
+		client.Report.finding(d.String())
+	}
+	return nil
+}
+
+// Lint запускает проверки checks для кода целевого каталога и возвращает
+// замечания. Внешний анализатор, которого нет в PATH, пропускается.
+// Ошибка — только отмена ctx или сбой запуска проверки.
+func (w Workspace) Lint(ctx context.Context, checks ...string) ([]Diagnostic, error) {
+	var diags []Diagnostic
+	for _, check := range checks {
+		var found []Diagnostic
+		var err error
+		switch check {
+		case CheckGofmt:
+			found, err = w.gofmtCheck()
+		case CheckVet:
+			found, err = w.toolCheck(ctx, check, "go", "vet")
+		default:
+			if _, lookErr := exec.LookPath(check); lookErr != nil {
+				w.logger().Info(i18n.T("Анализатор не установлен, проверка пропущена"), "check", check)
+				continue
+			}
+			found, err = w.toolCheck(ctx, check, check)
+		}
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, found...)
+	}
+	return diags, nil
+}
+
+// gofmtCheck — аналог gofmt -l для .go-файлов целевого каталога: файл, который
+// не разбирается или отличается от отформатированного, даёт замечание.
+func (w Workspace) gofmtCheck() ([]Diagnostic, error) {
+	var diags []Diagnostic
+	err := filepath.WalkDir(w.Target(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != w.Target() && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel := w.Rel([]string{path})[0]
+		formatted, err := format.Source(src)
+		switch {
+		case err != nil:
+			diags = append(diags, Diagnostic{Check: CheckGofmt, File: rel, Message: err.Error()})
+		case !bytes.Equal(src, formatted):
+			diags = append(diags, Diagnostic{Check: CheckGofmt, File: rel, Message: i18n.T("файл не отформатирован gofmt")})
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return diags, nil
+}
+
+// toolCheck запускает анализатор name ... ./<каталог>/... в корне проекта и
+// разбирает его вывод. Вывод без строк file:line — одно общее замечание.
+func (w Workspace) toolCheck(ctx context.Context, check, name string, args ...string) ([]Diagnostic, error) {
+	runCtx := ctx
+	if w.CompileTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, w.CompileTimeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(runCtx, name, append(args, w.packages())...)
+	cmd.Dir = w.Root
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf(i18n.T("%s прерван: %w"), check, ctx.Err())
+	}
+	if err == nil {
+		return nil, nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) && !errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf(i18n.T("не удалось запустить %s: %w"), check, err)
+	}
+	if diags := parseDiagnostics(check, string(output)); len(diags) > 0 {
+		return diags, nil
+	}
+	message := strings.TrimSpace(string(output))
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		message += fmt.Sprintf(i18n.T("\nпревышено время ожидания %s: %s"), check, w.CompileTimeout.Round(time.Millisecond))
+	}
+	if message == "" {
+		message = err.Error()
+	}
+	return []Diagnostic{{Check: check, Message: message}}, nil
+}
+
+// packages возвращает шаблон пакетов целевого каталога для go vet и анализаторов.
+func (w Workspace) packages() string {
+	if w.OutputDir == "." || w.OutputDir == "" {
+		return "./..."
+	}
+	return "./" + w.OutputDir + "/..."
+}
+
+// diagnosticLine — строка анализатора вида file.go:12:5: сообщение.
+var diagnosticLine = regexp.MustCompile(`^(?:vet: )?([^\s:]+\.go):(\d+)(?::(\d+))?:\s*(.+)$`)
+
+// parseDiagnostics выбирает из вывода анализатора строки file:line[:col]: message.
+func parseDiagnostics(check, output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := diagnosticLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		lineNum, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		diags = append(diags, Diagnostic{
+			Check:   check,
+			File:    filepath.ToSlash(strings.TrimPrefix(m[1], "./")),
+			Line:    lineNum,
+			Col:     col,
+			Message: m[4],
+		})
+	}
+	return diags
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_runTask_lint(t *testing.T) {
+	newSandbox(t)
+	srv := lmstudiotest.NewServer(t)
+	unformatted := domen.Command{Type: "create", Path: "prog/main.go", Content: strings.ReplaceAll(goodMainCode, "\t", "  ")}
+	vetIssue := domen.Command{Type: "create", Path: "prog/show.go", Content: "package main\n\nfunc show() {\n\tx := 1\n\tx = x\n\t_ = x\n}\n"}
+	srv.ReplyCommands(unformatted, vetIssue).ReplyCommands(createGoodTest)
+	srv.ReplyCommands(fixMain) // исправление замечания gofmt
+	report := NewTaskReport(greetingTask)
+
+	cfg := domen.Config{Endpoint: srv.Endpoint(), MaxTaskAttempts: 1, Format: FormatOff, Lint: "gofmt, vet:report, ralf-no-such-linter"}
+	if err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil); err != nil {
+		t.Fatalf("runTask() error = %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("runTask() made %d requests, want 3", len(requests))
+	}
+	if !containsMessage(requests[2].Messages, "user", "prog/main.go: файл не отформатирован gofmt (gofmt)") {
+		t.Errorf("fix request does not contain the gofmt finding")
+	}
+	if got := report.Stages[StageLint]; got == nil || *got != (StageReport{Attempts: 1, Passed: true}) {
+		t.Errorf("lint stage = %+v", got)
+	}
+	if len(report.Findings) != 1 || !strings.Contains(report.Findings[0], "prog/show.go:5") || !strings.HasSuffix(report.Findings[0], "(vet)") {
+		t.Errorf("report findings = %q, want the vet finding in prog/show.go", report.Findings)
+	}
+}
+
+func TestParseLintChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []LintCheck
+		wantErr bool
+	}{
+		{name: "empty", list: " , "},
+		{
+			name: "default policy is fix",
+			list: "gofmt, vet:report ,staticcheck:fix",
+			want: []LintCheck{{Name: "gofmt", Policy: LintFix}, {Name: "vet", Policy: LintReport}, {Name: "staticcheck", Policy: LintFix}},
+		},
+		{name: "unknown policy", list: "vet:ignore", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLintChecks(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLintChecks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLintChecks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseDiagnostics(t *testing.T) {
+	output := "# sandbox/prog\n" +
+		"vet: prog/show.go:6:2: fmt.Printf format %d has arg \"x\" of wrong type string\n" +
+		"./prog/main.go:3:6: func unused is unused (U1000)\n"
+	want := []Diagnostic{
+		{Check: "lint", File: "prog/show.go", Line: 6, Col: 2, Message: "fmt.Printf format %d has arg \"x\" of wrong type string"},
+		{Check: "lint", File: "prog/main.go", Line: 3, Col: 6, Message: "func unused is unused (U1000)"},
+	}
+	if got := parseDiagnostics("lint", output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiagnostics() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Режимы работы с LLM (domen.Config.LLMMode).
+const (
+	LLMModeLive   = "live"   // запросы идут в LM Studio
+	LLMModeRecord = "record" // запросы идут в LM Studio, пары запрос/ответ пишутся в кассету
+	LLMModeReplay = "replay" // ответы берутся из кассеты, сеть не используется
+)
+
+// ErrCassetteMiss возвращается в режиме replay, если для запроса нет записанного ответа.
+var ErrCassetteMiss = i18n.Error("в кассете нет ответа для запроса")
+
+// chatBackend выполняет запрос chat completions.
+type chatBackend interface {
+	complete(ctx context.Context, req chatRequest) (chatResponse, error)
+}
+
+// newChatBackend выбирает backend по режиму из конфигурации.
+func newChatBackend(cfg domen.Config, live chatBackend) (chatBackend, error) {
+	switch cfg.LLMMode {
+	case "", LLMModeLive:
+		return live, nil
+	case LLMModeRecord:
+		return &recordingBackend{next: live, cassette: newCassette(cfg.CassetteDir)}, nil
+	case LLMModeReplay:
+		return &replayBackend{cassette: newCassette(cfg.CassetteDir)}, nil
+	default:
+		return nil, fmt.Errorf(i18n.T("неизвестный режим LLM: %q (допустимо: live, record, replay)"), cfg.LLMMode)
+	}
+}
+
+// httpBackend отправляет запросы в OpenAI-совместимый endpoint LM Studio.
+type httpBackend struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (b *httpBackend) complete(ctx context.Context, req chatRequest) (chatResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return chatResponse{}, fmt.Errorf(i18n.T("не удалось маршалировать запрос: %w"), err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, b.BaseURL+"/chat/completions", bytes.NewReader(jsonData))
+	if err != nil {
+		return chatResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := b.HTTPClient.Do(httpReq)
+	if err != nil {
+		return chatResponse{}, fmt.Errorf(i18n.T("ошибка соединения с LM Studio: %w"), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return chatResponse{}, fmt.Errorf(i18n.T("LM Studio вернул код %d: %s"), resp.StatusCode, string(body))
+	}
+
+	var apiResp chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return chatResponse{}, fmt.Errorf(i18n.T("ошибка парсинга JSON ответа: %w"), err)
+	}
+	return apiResp, nil
+}
+
+// cassetteEntry — содержимое файла кассеты: запрос и все ответы на него по порядку.
+// Одинаковый запрос может повторяться (например, при повторной попытке),
+// поэтому ответы хранятся списком и при воспроизведении выдаются по очереди.
+type cassetteEntry struct {
+	Key       string         `json:"key"`
+	Model     string         `json:"model"`
+	Messages  []Message      `json:"messages"`
+	Responses []chatResponse `json:"responses"`
+}
+
+// cassette — каталог с записанными парами запрос/ответ, один файл на ключ запроса.
+type cassette struct {
+	dir      string
+	mu       sync.Mutex
+	served   map[string]int  // сколько ответов уже выдано по каждому ключу
+	recorded map[string]bool // ключи, уже перезаписанные в этом запуске
+}
+
+func newCassette(dir string) *cassette {
+	if dir == "" {
+		dir = "cassettes"
+	}
+	return &cassette{dir: dir, served: make(map[string]int), recorded: make(map[string]bool)}
+}
+
+// cassetteKey вычисляет ключ запроса: sha256 от нормализованных сообщений.
+// Модель и параметры генерации в ключ не входят, чтобы кассету можно было
+// воспроизводить с другими настройками.
+func cassetteKey(messages []Message) string {
+	h := sha256.New()
+	for _, m := range normalizeMessages(messages) {
+		fmt.Fprintf(h, "%s\x00%s\x00", m.Role, m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeMessages убирает различия, не влияющие на смысл запроса:
+// переводы строк Windows, пробелы в конце строк и по краям сообщения.
+func normalizeMessages(messages []Message) []Message {
+	result := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		content := strings.ReplaceAll(m.Content, "\r\n", "\n")
+		lines := strings.Split(content, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+		result = append(result, Message{
+			Role:    strings.ToLower(strings.TrimSpace(m.Role)),
+			Content: strings.TrimSpace(strings.Join(lines, "\n")),
+		})
+	}
+	return result
+}
+
+func (c *cassette) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *cassette) load(key string) (cassetteEntry, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return cassetteEntry{}, err
+	}
+	var entry cassetteEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return cassetteEntry{}, fmt.Errorf(i18n.T("повреждён файл кассеты %s: %w"), c.path(key), err)
+	}
+	return entry, nil
+}
+
+// record дописывает ответ на запрос в файл кассеты. Первый ответ на запрос
+// в запуске заменяет ответы, записанные прежними запусками: повторная запись
+// кассеты обновляет её, а не копит устаревшие ответы.
+func (c *cassette) record(req chatRequest, resp chatResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cassetteKey(req.Messages)
+	entry := cassetteEntry{Key: key, Model: req.Model, Messages: req.Messages}
+	if c.recorded[key] {
+		var err error
+		if entry, err = c.load(key); err != nil {
+			return err
+		}
+	}
+	c.recorded[key] = true
+	entry.Responses = append(entry.Responses, resp)
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf(i18n.T("не удалось создать каталог кассеты: %w"), err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(key), data, 0644)
+}
+
+// next возвращает очередной записанный ответ на запрос. Когда записанные
+// ответы заканчиваются, повторяется последний.
+func (c *cassette) next(req chatRequest) (chatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cassetteKey(req.Messages)
+	entry, err := c.load(key)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(entry.Responses) == 0) {
+		return chatResponse{}, fmt.Errorf("%w: %s", ErrCassetteMiss, key)
+	}
+	if err != nil {
+		return chatResponse{}, err
+	}
+	i := min(c.served[key], len(entry.Responses)-1)
+	c.served[key]++
+	return entry.Responses[i], nil
+}
+
+// recordingBackend передаёт запросы дальше и записывает ответы в кассету.
+type recordingBackend struct {
+	next     chatBackend
+	cassette *cassette
+}
+
+func (b *recordingBackend) complete(ctx context.Context, req chatRequest) (chatResponse, error) {
+	resp, err := b.next.complete(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if err := b.cassette.record(req, resp); err != nil {
+		return resp, fmt.Errorf(i18n.T("не удалось записать ответ в кассету: %w"), err)
+	}
+	return resp, nil
+}
+
+// replayBackend отвечает записанными ответами из кассеты без обращения к сети.
+type replayBackend struct {
+	cassette *cassette
+}
+
+func (b *replayBackend) complete(ctx context.Context, req chatRequest) (chatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return chatResponse{}, err
+	}
+	return b.cassette.next(req)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// scriptedBackend отвечает заранее заданными ответами и считает вызовы.
+type scriptedBackend struct {
+	responses []string
+	calls     int
+}
+
+func (b *scriptedBackend) complete(_ context.Context, req chatRequest) (chatResponse, error) {
+	content := b.responses[min(b.calls, len(b.responses)-1)]
+	b.calls++
+	return chatResponse{Choices: []chatChoice{{Message: Message{Role: "assistant", Content: content}}}}, nil
+}
+
+func Test_cassetteKey(t *testing.T) {
+	a := []Message{{Role: "system", Content: "правила"}, {Role: "user", Content: "задача\r\nстрока  \n"}}
+	b := []Message{{Role: "system", Content: "правила"}, {Role: "user", Content: "задача\nстрока"}}
+	c := []Message{{Role: "system", Content: "правила"}, {Role: "user", Content: "другая задача"}}
+	if cassetteKey(a) != cassetteKey(b) {
+		t.Errorf("cassetteKey() differs for messages equal after normalization")
+	}
+	if cassetteKey(a) == cassetteKey(c) {
+		t.Errorf("cassetteKey() equal for different messages")
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	cfg := domen.Config{CassetteDir: t.TempDir()}
+	live := &scriptedBackend{responses: []string{"первый", "второй"}}
+
+	cfg.LLMMode = LLMModeRecord
+	recorder, err := newChatBackend(cfg, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := chatRequest{Model: "local-model", Messages: []Message{{Role: "user", Content: "задача"}}}
+	for _, want := range []string{"первый", "второй"} {
+		resp, err := recorder.complete(t.Context(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Choices[0].Message.Content; got != want {
+			t.Errorf("record complete() = %q, want %q", got, want)
+		}
+	}
+
+	cfg.LLMMode = LLMModeReplay
+	replayer, err := newChatBackend(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ответы выдаются по порядку записи, затем повторяется последний
+	for _, want := range []string{"первый", "второй", "второй"} {
+		resp, err := replayer.complete(t.Context(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Choices[0].Message.Content; got != want {
+			t.Errorf("replay complete() = %q, want %q", got, want)
+		}
+	}
+	if live.calls != 2 {
+		t.Errorf("live backend calls = %d, want 2", live.calls)
+	}
+
+	_, err = replayer.complete(t.Context(), chatRequest{Messages: []Message{{Role: "user", Content: "незаписанный запрос"}}})
+	if !errors.Is(err, ErrCassetteMiss) {
+		t.Errorf("replay complete() error = %v, want ErrCassetteMiss", err)
+	}
+
+	if _, err := newChatBackend(domen.Config{LLMMode: "offline"}, live); err == nil {
+		t.Errorf("newChatBackend() accepted unknown mode")
+	}
+}
+
+func TestRecordReplay_rerecord(t *testing.T) {
+	dir := t.TempDir()
+	req := chatRequest{Messages: []Message{{Role: "user", Content: "задача"}}}
+	for _, answers := range [][]string{{"старый", "старый"}, {"новый"}} {
+		recorder, err := newChatBackend(domen.Config{LLMMode: LLMModeRecord, CassetteDir: dir}, &scriptedBackend{responses: answers})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range answers {
+			if _, err := recorder.complete(t.Context(), req); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	entry, err := newCassette(dir).load(cassetteKey(req.Messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Responses) != 1 || entry.Responses[0].Choices[0].Message.Content != "новый" {
+		t.Errorf("re-recorded responses = %+v, want only the new one", entry.Responses)
+	}
+}
+
+func Test_processTask_recordReplay(t *testing.T) {
+	// кассеты и отчёты по умолчанию лежат внутри проекта и не должны менять запросы
+	newSandbox(t)
+	srv := lmstudiotest.NewServer(t)
+	srv.ReplyCommands(createGoodMain).ReplyCommands(createGoodTest)
+
+	cfg := domen.Config{Endpoint: srv.Endpoint(), LLMMode: LLMModeRecord}
+	if err := processTask(t.Context(), greetingTask, cfg); err != nil {
+		t.Fatalf("record processTask() error = %v", err)
+	}
+	if _, err := os.Stat(domen.DefaultConfig().CassetteDir); err != nil {
+		t.Fatalf("cassette is not written to the default dir: %v", err)
+	}
+	// состояние проекта до задачи, как после ralf reset, и отчёт прошлого запуска
+	if err := os.RemoveAll("prog"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(domen.DefaultConfig().ReportDir, "report.json"), "{}")
+
+	cfg.LLMMode = LLMModeReplay
+	if err := processTask(t.Context(), greetingTask, cfg); err != nil {
+		t.Fatalf("replay processTask() error = %v", err)
+	}
+	if got := len(srv.Requests()); got != 2 {
+		t.Errorf("LM Studio got %d requests, want 2 (only while recording)", got)
+	}
+	if _, err := os.Stat(filepath.Join("prog", "main.go")); err != nil {
+		t.Errorf("replay did not create prog/main.go: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"net/http"
+	"strings"
+)
+
+// ModelRef — модель и адрес OpenAI-совместимого API, на котором она запущена.
+type ModelRef struct {
+	Name     string
+	Endpoint string // пусто — основной адрес из настроек
+}
+
+// String возвращает модель в том же виде, в каком она задаётся в настройках.
+func (m ModelRef) String() string {
+	if m.Endpoint == "" {
+		return m.Name
+	}
+	return m.Name + "@" + m.Endpoint
+}
+
+// ParseModelChain разбирает список моделей через запятую: «имя» или
+// «имя@http://адрес» для модели на другом API. Сам символ @ в имени
+// допустим (LM Studio так обозначает квантизацию), адресом считается
+// только часть, начинающаяся с http:// или https://.
+func ParseModelChain(list string) []ModelRef {
+	var chain []ModelRef
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ref := ModelRef{Name: item}
+		for _, scheme := range []string{"@http://", "@https://"} {
+			if i := strings.LastIndex(item, scheme); i > 0 {
+				ref = ModelRef{Name: item[:i], Endpoint: strings.TrimRight(item[i+1:], "/")}
+				break
+			}
+		}
+		chain = append(chain, ref)
+	}
+	return chain
+}
+
+// backendsFor создаёт backend для каждого адреса API, встречающегося в цепочках.
+func backendsFor(cfg domen.Config, client *http.Client, chains ...[]ModelRef) (map[string]chatBackend, error) {
+	backends := make(map[string]chatBackend)
+	for _, chain := range chains {
+		for _, ref := range chain {
+			if _, ok := backends[ref.Endpoint]; ok || ref.Endpoint == "" {
+				continue
+			}
+			backend, err := newChatBackend(cfg, &httpBackend{BaseURL: ref.Endpoint, HTTPClient: client})
+			if err != nil {
+				return nil, err
+			}
+			backends[ref.Endpoint] = backend
+		}
+	}
+	return backends, nil
+}
+
+// stageChains возвращает цепочки моделей этапов из настроек. Этапы без
+// цепочки в результат не попадают.
+func stageChains(cfg domen.Config) map[string][]ModelRef {
+	chains := make(map[string][]ModelRef)
+	for stage, list := range map[string]string{
+		StageSolve:       cfg.SolveModels,
+		StageCompile:     cfg.CompileModels,
+		StageTests:       cfg.TestsModels,
+		StageTestCompile: cfg.TestCompileModels,
+	} {
+		if chain := ParseModelChain(list); len(chain) > 0 {
+			chains[stage] = chain
+		}
+	}
+	return chains
+}
+
+// useModel переключает запросы клиента на модель ref: меняет имя модели,
+// а для модели на другом API — и backend. Размер контекста, если он не задан
+// в настройках, берётся для новой модели из таблицы.
+func (c *LLMClient) useModel(ref ModelRef) {
+	c.Model = ref.Name
+	if c.autoContext {
+		c.ContextSize = ModelContextSize(c.Model)
+	}
+	endpoint := ref.Endpoint
+	if endpoint == "" {
+		endpoint = c.endpoint
+	}
+	if backend, ok := c.backends[endpoint]; ok && endpoint != c.BaseURL {
+		c.BaseURL = endpoint
+		c.Backend = backend
+	}
+}
+
+// chainLevel возвращает номер модели цепочки этапа stage на достигнутом
+// в задаче уровне: уровень общий для всех этапов, а короткая цепочка
+// остаётся на последней модели. -1 — у этапа нет цепочки.
+func (c *LLMClient) chainLevel(stage string) int {
+	return min(c.level, len(c.Chains[stage])-1)
+}
+
+// escalate переводит этап на следующую модель его цепочки, а с ним и
+// следующие этапы задачи. Возвращает false, если цепочки нет или она уже
+// пройдена до конца.
+func (c *LLMClient) escalate() bool {
+	chain := c.Chains[c.Stage]
+	level := c.chainLevel(c.Stage)
+	if level+1 >= len(chain) {
+		return false
+	}
+	c.level = level + 1
+	c.useModel(chain[c.level])
+	c.Log.Info(i18n.T("Переходим к следующей модели цепочки"), logging.KeyStage, c.Stage, "model", c.Model)
+	return true
+}
+
+// escalatePast поднимает уровень цепочек задачи так, чтобы этап stage, который
+// работал на модели номер from, перешёл к следующей. Возвращает false, если
+// следующей модели в цепочке этапа нет.
+func (c *LLMClient) escalatePast(stage string, from int) bool {
+	if from < 0 || from+1 >= len(c.Chains[stage]) {
+		return false
+	}
+	c.level = max(c.level, from+1)
+	return true
+}
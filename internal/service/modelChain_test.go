@@ -0,0 +1,121 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"reflect"
+	"testing"
+)
+
+func TestParseModelChain(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want []ModelRef
+	}{
+		{name: "empty", list: " , ", want: nil},
+		{name: "names", list: "qwen-7b, qwen-32b", want: []ModelRef{{Name: "qwen-7b"}, {Name: "qwen-32b"}}},
+		{
+			name: "endpoint",
+			list: "small,big@http://gpu:1234/v1/",
+			want: []ModelRef{{Name: "small"}, {Name: "big", Endpoint: "http://gpu:1234/v1"}},
+		},
+		{name: "quantization tag", list: "qwen@q4_k_m", want: []ModelRef{{Name: "qwen@q4_k_m"}}},
+		{
+			name: "quantization tag and endpoint",
+			list: "qwen@q8_0@https://box/v1",
+			want: []ModelRef{{Name: "qwen@q8_0", Endpoint: "https://box/v1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseModelChain(tt.list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseModelChain(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_runTask_escalation(t *testing.T) {
+	newSandbox(t)
+	local := lmstudiotest.NewServer(t)
+	remote := lmstudiotest.NewServer(t)
+	local.ReplyCommands(createBadMain).ReplyCommands(stillBadMain)
+	remote.ReplyCommands(fixMain).ReplyCommands(createGoodTest)
+	report := NewTaskReport(greetingTask)
+
+	cfg := domen.Config{
+		Endpoint:              local.Endpoint(),
+		MaxTaskAttempts:       1,
+		MaxCompileFixAttempts: 3,
+		CompileModels:         "small, big@" + remote.Endpoint(),
+		TestsModels:           "small, big@" + remote.Endpoint(),
+		EscalateAfter:         1,
+	}
+	err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil)
+	report.finish(err, nil)
+	if err != nil {
+		t.Fatalf("runTask() error = %v", err)
+	}
+
+	var localModels, remoteModels []string
+	for _, req := range local.Requests() {
+		localModels = append(localModels, req.Model)
+	}
+	for _, req := range remote.Requests() {
+		remoteModels = append(remoteModels, req.Model)
+	}
+	// solve — основная модель, исправления — по цепочке этапа compile, а tests
+	// начинается с уровня цепочки, которого достигла задача
+	if want := []string{"local-model", "small"}; !reflect.DeepEqual(localModels, want) {
+		t.Errorf("local models = %v, want %v", localModels, want)
+	}
+	if want := []string{"big", "big"}; !reflect.DeepEqual(remoteModels, want) {
+		t.Errorf("remote models = %v, want %v", remoteModels, want)
+	}
+	if report.SolvedBy != "big" {
+		t.Errorf("SolvedBy = %q, want big", report.SolvedBy)
+	}
+}
+
+func Test_runTask_solveEscalation(t *testing.T) {
+	newSandbox(t)
+	local := lmstudiotest.NewServer(t)
+	remote := lmstudiotest.NewServer(t)
+	local.ReplyCommands(createBadMain).ReplyCommands(stillBadMain).ReplyCommands(createGoodTest)
+	remote.ReplyCommands(createGoodMain)
+	report := NewTaskReport(greetingTask)
+
+	cfg := domen.Config{
+		Endpoint:              local.Endpoint(),
+		MaxTaskAttempts:       1,
+		MaxCompileFixAttempts: 1,
+		SolveModels:           "small, big@" + remote.Endpoint(),
+	}
+	err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil)
+	report.finish(err, nil)
+	if err != nil {
+		t.Fatalf("runTask() error = %v", err)
+	}
+
+	var localModels, remoteModels []string
+	for _, req := range local.Requests() {
+		localModels = append(localModels, req.Model)
+	}
+	for _, req := range remote.Requests() {
+		remoteModels = append(remoteModels, req.Model)
+	}
+	// исправления сборки исчерпаны — задачу заново решает вторая модель цепочки solve
+	if want := []string{"small", "local-model", "local-model"}; !reflect.DeepEqual(localModels, want) {
+		t.Errorf("local models = %v, want %v", localModels, want)
+	}
+	if want := []string{"big"}; !reflect.DeepEqual(remoteModels, want) {
+		t.Errorf("remote models = %v, want %v", remoteModels, want)
+	}
+	if got := report.Stages[StageSolve]; got == nil || *got != (StageReport{Attempts: 2, Passed: true}) {
+		t.Errorf("solve stage = %+v", got)
+	}
+	if report.SolvedBy != "local-model" {
+		t.Errorf("SolvedBy = %q, want local-model (the model of the last call)", report.SolvedBy)
+	}
+}
@@ -2,197 +2,453 @@ package service
 
 import (
 	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"Ralf/internal/prompts"
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
 	"os/exec"
-	"path/filepath"
+	"path"
+	"regexp"
 	"strings"
 )
 
-// RunOrchestrator запускает обработку ВСЕХ задач со статусом "new"
-func RunOrchestrator(cfg domen.Config) error {
-	// значения по умолчанию
-	if cfg.TasksFilePath == "" {
-		cfg.TasksFilePath = "tasks.txt"
+// RunOrchestrator запускает обработку всех задач, подходящих под фильтр
+// (по умолчанию — ВСЕ задачи со статусом "new"). Каждая задача обрабатывается
+// в запуске не больше одного раза. Отмена ctx (например, по Ctrl-C) прерывает
+// текущий запрос к модели или сборку; изменения прерванной задачи откатываются
+// по журналу, а сама задача возвращается в статус new.
+func RunOrchestrator(ctx context.Context, cfg domen.Config, filter domen.TaskFilter) error {
+	// незаданные настройки берутся из domen.DefaultConfig
+	cfg = cfg.WithDefaults()
+	lang, err := i18n.ParseLang(cfg.Language)
+	if err != nil {
+		return err
 	}
-	if cfg.MaxCompileFixAttempts == 0 {
-		cfg.MaxCompileFixAttempts = 10
+	i18n.SetLanguage(lang)
+
+	slog.Info(i18n.T("Проверяем доступ к LM Studio, Go и каталогу проекта"))
+	// при воспроизведении из кассеты LM Studio не нужен
+	if cfg.LLMMode != LLMModeReplay {
+		if err := checkLMStudioAvailable(ctx, cfg.Endpoint); err != nil {
+			return fmt.Errorf(i18n.T("LM Studio недоступен: %w"), err)
+		}
 	}
-	if cfg.MaxTestAttempts == 0 {
-		cfg.MaxTestAttempts = 10
+	ws, err := NewWorkspace(cfg)
+	if err != nil {
+		return err
 	}
-	if cfg.WorkingDir == "" {
-		cfg.WorkingDir = "."
+	if err := checkGoAndFSAccess(ws); err != nil {
+		return fmt.Errorf(i18n.T("проблема с окружением Go или правами ФС: %w"), err)
 	}
 
-	fmt.Println("Приступаем к этапу анализа доступов.")
-	if err := checkLMStudioAvailable(); err != nil {
-		return fmt.Errorf("LM Studio недоступен: %w", err)
+	run := NewRunReport(cfg)
+	runTokens := NewTokenBudget(i18n.T("запуск"), cfg.MaxRunTokens, nil)
+	if cfg.Workers > 1 {
+		err = runParallel(ctx, cfg, ws, filter, run, runTokens)
+	} else {
+		err = runSequential(ctx, cfg, ws, filter, run, runTokens)
 	}
-	if err := checkGoAndFSAccess(); err != nil {
-		return fmt.Errorf("проблема с окружением Go или правами ФС: %w", err)
+	if len(run.Tasks) == 0 {
+		return err
 	}
+	jsonPath, mdPath, reportErr := run.Write(cfg.ReportDir)
+	if reportErr != nil {
+		return errors.Join(err, reportErr)
+	}
+	slog.Info(i18n.T("Отчёт о запуске записан"), "json", jsonPath, "markdown", mdPath)
+	return err
+}
 
-	fmt.Println("Начинаем цикл обработки задач.")
+// runSequential обрабатывает задачи по одной прямо в проекте. Когда исчерпан
+// бюджет токенов запуска, новые задачи не начинаются и остаются в статусе new.
+func runSequential(ctx context.Context, cfg domen.Config, ws Workspace, filter domen.TaskFilter, run *RunReport, runTokens *TokenBudget) error {
+	slog.Info(i18n.T("Начинаем цикл обработки задач"))
 
 	processed := 0
+	attempted := make(map[int]bool)
 	for {
-		task, err := GetNewTask(cfg.TasksFilePath)
+		if err := runTokens.check(); err != nil {
+			slog.Warn(i18n.T("Бюджет запуска исчерпан, оставшиеся задачи не начаты"), logging.KeyError, err)
+			return err
+		}
+		task, err := NextTask(cfg.TasksFilePath, filter, attempted)
 		if err != nil {
-			if errors.Is(err, errors.New("не найдено задач со статусом new")) {
-				fmt.Printf("Все задачи обработаны успешно. Обработано задач: %d\n", processed)
+			if errors.Is(err, ErrNoNewTasks) {
+				slog.Info(i18n.T("Все задачи обработаны"), "processed", processed)
 				return nil
 			}
-			return fmt.Errorf("ошибка получения задачи: %w", err)
+			return fmt.Errorf(i18n.T("ошибка получения задачи: %w"), err)
 		}
 
-		fmt.Printf("Получили задачу в тексте %d\n", task.Num)
-		fmt.Println("Меняем статус на run.")
+		log := slog.With(logging.KeyTask, task.Num)
+		log.Info(i18n.T("Взяли задачу в работу"))
+		attempted[task.Num] = true
 
 		if err := UpdateTaskStatus(cfg.TasksFilePath, task.Num, domen.StatusRun); err != nil {
-			return fmt.Errorf("не удалось обновить статус run: %w", err)
+			return fmt.Errorf(i18n.T("не удалось обновить статус run: %w"), err)
 		}
 
-		fmt.Println("Приступаем к Process task:")
-		if err := processTask(task, cfg); err != nil {
+		report := NewTaskReport(task)
+		run.Tasks = append(run.Tasks, report)
+		changes := NewChangeSet()
+		err = runPlanned(ctx, task, cfg, changes, report, runTokens)
+		report.finish(err, ws.Rel(changes.Paths()))
+		if ctx.Err() != nil {
+			return interrupt(ctx, cfg, log, task)
+		}
+		if attemptsErr := UpdateTaskAttempts(cfg.TasksFilePath, task.Num, report.TaskAttempts); attemptsErr != nil {
+			return fmt.Errorf(i18n.T("не удалось записать число попыток задачи: %w"), attemptsErr)
+		}
+		if err != nil {
 			_ = UpdateTaskStatus(cfg.TasksFilePath, task.Num, domen.StatusError)
-			fmt.Printf("Задача %d завершилась ошибкой: %v\n", task.Num, err)
+			log.Error(i18n.T("Задача завершилась ошибкой"), logging.KeyError, err)
 			// Продолжаем обработку следующих задач, не выходим!
 			continue
 		}
 
-		fmt.Println("Меняем статус на ok.")
+		log.Info(i18n.T("Задача выполнена"))
 		if err := UpdateTaskStatus(cfg.TasksFilePath, task.Num, domen.StatusOK); err != nil {
-			return fmt.Errorf("не удалось обновить статус ok: %w", err)
+			return fmt.Errorf(i18n.T("не удалось обновить статус ok: %w"), err)
 		}
 
 		processed++
 	}
 }
 
+// interrupt возвращает прерванную задачу в статус new и сообщает об отмене.
+func interrupt(ctx context.Context, cfg domen.Config, log *slog.Logger, task domen.Task) error {
+	log.Warn(i18n.T("Обработка прервана, задача возвращена в статус new"))
+	if err := UpdateTaskStatus(cfg.TasksFilePath, task.Num, domen.StatusNew); err != nil {
+		return fmt.Errorf(i18n.T("не удалось обновить статус new: %w"), err)
+	}
+	return fmt.Errorf(i18n.T("обработка прервана: %w"), ctx.Err())
+}
+
 // checkLMStudioAvailable проверяет доступность LM Studio простым запросом.
-func checkLMStudioAvailable() error {
-	_, err := http.Get("http://localhost:1234/v1/models")
+func checkLMStudioAvailable(ctx context.Context, endpoint string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(endpoint, "/")+"/models", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf(i18n.T("LM Studio вернул код %d: %s"), resp.StatusCode, resp.Status)
+	}
 	return nil
 }
 
-// checkGoAndFSAccess проверяет наличие go и права на запись/исполнение.
-func checkGoAndFSAccess() error {
+// checkGoAndFSAccess проверяет наличие go и права на запись в целевой каталог проекта.
+func checkGoAndFSAccess(ws Workspace) error {
 	if _, err := exec.LookPath("go"); err != nil {
 		return err
 	}
-	if err := os.MkdirAll("tmp", 0755); err != nil {
+	return ws.CheckAccess()
+}
+
+// processTask выполняет полный цикл для одной задачи. Если включён git,
+// успешная задача коммитится, а изменения неудачной откатываются.
+func processTask(ctx context.Context, task domen.Task, cfg domen.Config) error {
+	return runPlanned(ctx, task, cfg, NewChangeSet(), nil, nil)
+}
+
+// runTask выполняет полный цикл задачи и записывает в changes файлы,
+// затронутые командами модели, а в report — ход этапов (report может быть nil).
+// Неудачная попытка повторяется с исходного состояния файлов — не больше
+// cfg.MaxTaskAttempts раз, каждый раз с другой стратегией (см. prepareAttempt).
+// Токены задачи учитываются и в бюджете запуска runTokens (nil — без ограничения).
+// При отмене ctx файлы, затронутые задачей, восстанавливаются по журналу changes.
+func runTask(ctx context.Context, task domen.Task, cfg domen.Config, changes *ChangeSet, report *TaskReport, runTokens *TokenBudget) (err error) {
+	cfg = cfg.WithDefaults()
+
+	client, err := NewLLMClient(cfg)
+	if err != nil {
+		return err
+	}
+	// список проверок качества проверяется до первого запроса к модели
+	if _, err := ParseLintChecks(cfg.Lint); err != nil {
+		return err
+	}
+	client.Log = client.Log.With(logging.KeyTask, task.Num)
+	client.PromptLog = client.PromptLog.With(logging.KeyTask, task.Num)
+	client.Workspace.Log = client.Log
+	client.Workspace.Changes = changes
+	client.Report = report
+	client.Tokens = NewTokenBudget(i18n.T("задачу"), cfg.MaxTaskTokens, runTokens)
+	ws := client.Workspace
+
+	vcs, err := startTaskGit(ws, cfg.GitMode, task)
+	if err != nil {
 		return err
 	}
-	return os.RemoveAll("tmp")
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			if rbErr := changes.Rollback(); rbErr != nil {
+				err = errors.Join(err, fmt.Errorf(i18n.T("не удалось откатить изменения задачи: %w"), rbErr))
+			}
+		}
+		err = vcs.finish(task, ws.Changes, err)
+	}()
+	// Контекст уже существующего кода, чтобы модель могла расширять прежние файлы
+	repoContext := BuildRepoContext(ws.Root, ws.OutputDir, task, client.repoContextBudget(), client.Tokenizer, ws.Excluded...)
+
+	// Лимит cfg.TaskTimeout действует на все попытки задачи вместе
+	taskCtx, cancel := withTimeout(ctx, cfg.TaskTimeout, i18n.T("задачу"))
+	defer cancel()
+	for attempt := 1; ; attempt++ {
+		client.prepareAttempt(cfg, attempt, err)
+		report.newAttempt()
+		err = attemptTask(taskCtx, client, task, cfg, repoContext)
+		if err == nil || !retryable(taskCtx, err) {
+			return err
+		}
+		if attempt >= cfg.MaxTaskAttempts {
+			if attempt > 1 {
+				err = fmt.Errorf(i18n.T("задача не решена за %d попыток: %w"), attempt, err)
+			}
+			return err
+		}
+		client.Log.Warn(i18n.T("Попытка задачи не удалась, начинаем заново"),
+			logging.KeyAttempt, attempt, "max_attempts", cfg.MaxTaskAttempts, logging.KeyError, err)
+		// следующая попытка начинается с исходного состояния проекта
+		if rbErr := changes.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf(i18n.T("не удалось откатить изменения задачи: %w"), rbErr))
+		}
+	}
 }
 
-// processTask выполняет полный цикл для одной задачи
-func processTask(task domen.Task, cfg domen.Config) error {
-	fmt.Println("Отправляем структуру task в llm.")
+// attemptTask выполняет одну попытку задачи с чистой историей переписки:
+// этапы идут по порядку, каждый ограничен cfg.StageTimeout.
+func attemptTask(ctx context.Context, client *LLMClient, task domen.Task, cfg domen.Config, repoContext string) error {
+	ws := client.Workspace
+	report := client.Report
+	// История переписки с LLM по задаче: задача, команды, диагностики
+	history := NewTaskHistory()
+
+	runStage := func(parent context.Context, stage string, fn func(ctx context.Context) error) error {
+		client.enterStage(stage)
+		stageCtx, cancel := withTimeout(parent, cfg.StageTimeout, fmt.Sprintf(i18n.T("этап %s"), stage))
+		defer cancel()
+		return budgetError(stageCtx, fn(stageCtx))
+	}
+
+	// Синтаксические ошибки в ответе модели: файлы не записаны, и модель увидит
+	// ошибки на следующем этапе сборки вместо лога go build
+	var syntax strings.Builder
 
-	// 1. Основной код + тесты
-	commands, err := SendTaskToLLM(task)
+	// 1-2. Решение и сборка. Если исправления сборки не помогли, а в цепочке
+	// этапа solve есть модель мощнее той, что решала задачу, задача решается
+	// ею заново с исходного состояния файлов.
+	for {
+		solvedAt := client.chainLevel(StageSolve)
+		err := runStage(ctx, StageSolve, func(ctx context.Context) error {
+			if cfg.Candidates > 1 {
+				if err := solveBestOf(ctx, client, task, repoContext, history, cfg.Candidates, &syntax); err != nil {
+					return err
+				}
+				report.pass(StageSolve)
+				return nil
+			}
+			report.attempt(StageSolve)
+			commands, err := client.SendTask(ctx, task, repoContext, history)
+			if err != nil {
+				return fmt.Errorf(i18n.T("ошибка получения решения от LM Studio: %w"), err)
+			}
+			if execErrs := client.applyCommands(ctx, commands, &syntax); len(execErrs) > 0 {
+				return fmt.Errorf(i18n.T("ошибка выполнения команды: %w"), execErrs[0])
+			}
+			report.pass(StageSolve)
+			return nil
+		})
+		if err == nil {
+			// Цикл исправления компиляции (с номером попытки)
+			err = runStage(ctx, StageCompile, func(ctx context.Context) error {
+				pending := syntax.String()
+				syntax.Reset()
+				return fixLoop(ctx, client, history, StageCompile, ws.Compile, ws.Path("main.go"), cfg.MaxCompileFixAttempts, pending)
+			})
+		}
+		if err == nil {
+			break
+		}
+		if !retryable(ctx, err) || !client.escalatePast(StageSolve, solvedAt) {
+			return err
+		}
+		client.Log.Warn(i18n.T("Решение не собралось, задача решается заново следующей моделью цепочки"), logging.KeyError, err)
+		if rbErr := ws.Changes.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf(i18n.T("не удалось откатить изменения задачи: %w"), rbErr))
+		}
+		history = NewTaskHistory()
+		syntax.Reset()
+	}
+
+	// 3. Генерация тестов
+	if err := runStage(ctx, StageTests, func(ctx context.Context) error {
+		report.attempt(StageTests)
+		testCommands, testErr := generateTests(ctx, client, task, history)
+		if testErr != nil {
+			return fmt.Errorf(i18n.T("ошибка генерации тестов: %w"), testErr)
+		}
+		if execErrs := client.applyCommands(ctx, testCommands, &syntax); len(execErrs) > 0 {
+			return fmt.Errorf(i18n.T("ошибка выполнения команд тестов: %w"), execErrs[0])
+		}
+		report.pass(StageTests)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	checks, err := ParseLintChecks(cfg.Lint)
 	if err != nil {
-		return fmt.Errorf("ошибка получения решения от LM Studio: %w", err)
+		return err
 	}
-	fmt.Println("Начинаем выполнять полученные команды:")
-	for _, cmd := range commands {
-		if _, execErr := ExecuteCommand(cmd); execErr != nil {
-			return fmt.Errorf("ошибка выполнения команды: %w", execErr)
+	// Проверки собранного решения. Ревью повторяет их после каждой доработки,
+	// чтобы доработка не вернула то, что они уже отклонили.
+	verify := func(ctx context.Context) error {
+		// 4. Компиляция тестов
+		if err := runStage(ctx, StageTestCompile, func(ctx context.Context) error {
+			pending := syntax.String()
+			syntax.Reset()
+			return fixLoop(ctx, client, history, StageTestCompile, ws.CompileTests, testFilePath(ws.OutputDir, task), cfg.MaxTestAttempts, pending)
+		}); err != nil {
+			return fmt.Errorf(i18n.T("ошибка компиляции тестов: %w"), err)
 		}
+
+		// 5. Покрытие кода задачи тестами
+		if err := runStage(ctx, StageCoverage, func(ctx context.Context) error {
+			return coverageStage(ctx, client, task, history, cfg.MinCoverage, cfg.MaxCoverageRounds, cfg.MaxTestAttempts)
+		}); err != nil {
+			return err
+		}
+
+		// 6. Проверки качества: gofmt, go vet, анализаторы
+		if len(checks) > 0 {
+			if err := runStage(ctx, StageLint, func(ctx context.Context) error {
+				return lintStage(ctx, client, history, checks, cfg.MaxCompileFixAttempts)
+			}); err != nil {
+				return fmt.Errorf(i18n.T("замечания анализаторов не исправлены: %w"), err)
+			}
+		}
+		return nil
+	}
+	if err := verify(ctx); err != nil {
+		return err
 	}
 
-	// 2. Цикл исправления компиляции (с номером попытки)
-	for i := 0; i < cfg.MaxCompileFixAttempts; i++ {
-		compileLog, compileErr := Compile(".")
+	// 7. Ревью решения: требования задачи, рискованные места, стиль
+	if cfg.Review == ReviewOn {
+		return runStage(ctx, StageReview, func(ctx context.Context) error {
+			// после доработки: сборка кода, затем все проверки заново
+			recheck := func(ctx context.Context, pending string) error {
+				if err := runStage(ctx, StageCompile, func(ctx context.Context) error {
+					return fixLoop(ctx, client, history, StageCompile, ws.Compile, ws.Path("main.go"), cfg.MaxCompileFixAttempts, pending)
+				}); err != nil {
+					return err
+				}
+				err := verify(ctx)
+				client.enterStage(StageReview)
+				return err
+			}
+			return reviewLoop(ctx, client, task, history, cfg.MaxReviewFixes, recheck)
+		})
+	}
+	return nil
+}
+
+// fixLoop компилирует проект и, пока есть ошибки, отправляет их модели и применяет
+// исправления — не больше maxAttempts раз. Ответ, который не удалось разобрать или
+// применить, расходует попытку: модель увидит его в истории. Ошибка связи с LM Studio
+// и отмена ctx прерывают цикл сразу. Если у этапа есть цепочка моделей, после каждых
+// client.EscalateAfter неудачных исправлений запросы переходят к следующей модели.
+// Попытки и итог учитываются в отчёте задачи как этап stage.
+// Синтаксические ошибки в командах модели (pending — из предыдущего этапа)
+// отправляются модели сразу, без сборки: такие файлы не записываются.
+func fixLoop(ctx context.Context, client *LLMClient, history *TaskHistory, stage string, compile func(context.Context) (string, error), path string, maxAttempts int, pending string) error {
+	for i := 0; ; i++ {
+		compileLog, compileErr := pending, errSyntax
+		if pending == "" {
+			compileLog, compileErr = compile(ctx)
+		}
+		pending = ""
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if compileErr == nil {
-			break
+			client.Report.pass(stage)
+			return nil
+		}
+		if i >= maxAttempts {
+			return fmt.Errorf(i18n.T("не удалось исправить ошибки компиляции за %d попыток:\n%s"), maxAttempts, compileLog)
 		}
 
-		fmt.Printf("Попытка исправления %d/%d...\n", i+1, cfg.MaxCompileFixAttempts)
+		// каждые EscalateAfter неудачных исправлений — следующая модель цепочки
+		if i > 0 && client.EscalateAfter > 0 && i%client.EscalateAfter == 0 {
+			client.escalate()
+		}
+
+		log := client.Log.With(logging.KeyStage, stage, logging.KeyAttempt, i+1)
+		log.Info(i18n.T("Отправляем модели ошибки сборки"), "max_attempts", maxAttempts)
+		client.Report.attempt(stage)
 
-		fixResp, fixErr := SendCompilationError(
-			"prog/main.go",
+		fixResp, fixErr := client.SendCompilationError(
+			ctx,
+			path,
 			compileLog,
 			i+1, // ← передаём номер попытки
+			history,
 		)
 		if fixErr != nil {
-			fmt.Println("Не удалось отправить ошибку компиляции")
-			break
+			return fmt.Errorf(i18n.T("ошибка получения исправления от LM Studio: %w"), fixErr)
 		}
 
 		fixCommands, parseErr := ParseCommands(fixResp)
 		if parseErr != nil {
-			fmt.Println("Не удалось распарсить исправления")
-			break
-		}
-
-		for _, cmd := range fixCommands {
-			ExecuteCommand(cmd)
-		}
-	}
-
-	// 3. Генерация тестов
-	testCommands, testErr := generateTests(task)
-	if testErr != nil {
-		return fmt.Errorf("ошибка генерации тестов: %w", testErr)
-	}
-	for _, cmd := range testCommands {
-		if _, execErr := ExecuteCommand(cmd); execErr != nil {
-			return fmt.Errorf("ошибка выполнения команд тестов: %w", execErr)
+			log.Warn(i18n.T("Не удалось разобрать исправления"), logging.KeyError, parseErr)
+			continue
 		}
-	}
 
-	// 4. Компиляция тестов
-	for i := 0; i < cfg.MaxTestAttempts; i++ {
-		_, testCompileErr := Compile(".")
-		if testCompileErr == nil {
-			return nil // всё успешно
-		}
-		// исправление тестов (можно тоже через SendCompilationError, но пока оставляем как было)
-		testFixResp, _ := SendCompilationError("_test.go", "ошибка компиляции тестов", 1)
-		testFixCmds, _ := ParseCommands(testFixResp)
-		for _, cmd := range testFixCmds {
-			ExecuteCommand(cmd)
+		var syntax strings.Builder
+		for _, execErr := range client.applyCommands(ctx, fixCommands, &syntax) {
+			log.Warn(i18n.T("Исправление не применилось"), logging.KeyError, execErr)
 		}
+		pending = syntax.String()
 	}
-	return nil
 }
 
 // generateTests отправляет LM Studio запрос на генерацию ТОЛЬКО тестов
-func generateTests(task domen.Task) ([]domen.Command, error) {
-	testPrompt := fmt.Sprintf(`Это уже решённая задача №%d.
-Сигнатура функции: %s
-
-Теперь напиши ТОЛЬКО тесты в формате JSON-массива команд.
-Тесты должны быть в файле prog/%s_test.go
-Используй пакет testing и таблицу тестов.
-Не трогай основной код — только создавай/редактируй тестовый файл.
-
-Пример:
-[
-  {
-    "Type": "создание",
-    "Path": "prog/%s_test.go",
-    "Content": "package main_test\\n\\nimport (\\n\\t\\"testing\\"\\n)\\n\\nfunc TestGreeting(t *testing.T) {\\n\\t// тесты здесь\\n}"
-  }
-]
-
-Верни ТОЛЬКО JSON-массив.`,
-		task.Num,
-		task.FuncSignature,
-		strings.TrimSuffix(filepath.Base(task.FuncSignature), " string) string"), // имя функции без сигнатуры
-		strings.TrimSuffix(filepath.Base(task.FuncSignature), " string) string"))
+func generateTests(ctx context.Context, client *LLMClient, task domen.Task, history *TaskHistory) ([]domen.Command, error) {
+	testPrompt, err := client.Prompts.Tests(prompts.TestsData{
+		Task:       task,
+		TestFile:   testFilePath(client.Workspace.OutputDir, task),
+		CreateType: commandTypeName(domen.CmdCreate),
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	// Создаём временный task только для тестов (чтобы не менять оригинальный)
 	testTask := task
 	testTask.Description = testPrompt // переопределяем описание → LLM поймёт, что нужно тесты
 
-	return SendTaskToLLM(testTask)
+	return client.SendTask(ctx, testTask, "", history)
+}
+
+// funcName выделяет имя функции (или метода) из сигнатуры вида "func Greeting(name string) string".
+var funcName = regexp.MustCompile(`func\s+(?:\([^)]*\)\s*)?(\w+)`)
+
+// testFilePath возвращает путь к файлу тестов задачи: по имени функции из сигнатуры,
+// а если сигнатуры нет — по номеру задачи.
+func testFilePath(dir string, task domen.Task) string {
+	name := fmt.Sprintf("task%d", task.Num)
+	if m := funcName.FindStringSubmatch(task.FuncSignature); m != nil {
+		name = strings.ToLower(m[1])
+	}
+	return path.Join(dir, name+"_test.go")
 }
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	goodMainCode = "package main\n\nfunc Greeting(name string) string {\n\tif name == \"\" {\n\t\treturn \"Hello, World!\"\n\t}\n\treturn \"Hello, \" + name + \"!\"\n}\n\nfunc main() {}\n"
+	badMainCode  = "package main\n\nfunc Greeting(name string) string {\n\treturn greet(name)\n}\n\nfunc main() {}\n"
+	goodTestCode = "package main\n\nimport \"testing\"\n\nfunc TestGreeting(t *testing.T) {\n\tif Greeting(\"\") != \"Hello, World!\" {\n\t\tt.Fail()\n\t}\n}\n"
+	badTestCode  = "package main\n\nimport \"testing\"\n\nfunc TestGreeting(t *testing.T) {\n\tif Greeting() != \"Hello, World!\" {\n\t\tt.Fail()\n\t}\n}\n"
+)
+
+var (
+	greetingTask = domen.Task{
+		Num:           1,
+		Description:   "Напишите функцию приветствия.",
+		FuncSignature: "func Greeting(name string) string",
+		Status:        domen.StatusRun,
+	}
+	createGoodMain = domen.Command{Type: "создание", Path: "prog/main.go", Content: goodMainCode}
+	createBadMain  = domen.Command{Type: "создание", Path: "prog/main.go", Content: badMainCode}
+	fixMain        = domen.Command{Type: "внесение изменений", Path: "prog/main.go", Content: goodMainCode}
+	stillBadMain   = domen.Command{Type: "edit", Path: "prog/main.go", Content: badMainCode}
+	createGoodTest = domen.Command{Type: "create", Path: "prog/greeting_test.go", Content: goodTestCode}
+	createBadTest  = domen.Command{Type: "создание", Path: "prog/greeting_test.go", Content: badTestCode}
+	fixTest        = domen.Command{Type: "внесение изменений", Path: "prog/greeting_test.go", Content: goodTestCode}
+)
+
+// newSandbox создаёт пустой Go-модуль во временном каталоге и переходит в него.
+func newSandbox(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module sandbox\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+}
+
+func Test_processTask(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       func(s *lmstudiotest.Server)
+		wantErr      string
+		wantRequests int
+		check        func(t *testing.T, requests []lmstudiotest.Request)
+	}{
+		{
+			name: "success",
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyCommands(createGoodMain).ReplyCommands(createGoodTest)
+			},
+			wantRequests: 2,
+		},
+		{
+			name: "malformed task response",
+			script: func(s *lmstudiotest.Server) {
+				s.Reply("Вот ваш код: package main")
+			},
+			wantErr:      "ошибка получения решения",
+			wantRequests: 1,
+		},
+		{
+			name: "broken chat completion body",
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyRaw("{oops")
+			},
+			wantErr:      "ошибка парсинга JSON ответа",
+			wantRequests: 1,
+		},
+		{
+			name: "server error",
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyStatus(http.StatusInternalServerError, "model crashed")
+			},
+			wantErr:      "500",
+			wantRequests: 1,
+		},
+		{
+			name: "compile error fixed with history",
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyCommands(createBadMain).ReplyCommands(fixMain).ReplyCommands(createGoodTest)
+			},
+			wantRequests: 3,
+			check: func(t *testing.T, requests []lmstudiotest.Request) {
+				fix := requests[1].Messages
+				if !strings.Contains(fix[len(fix)-1].Content, "undefined: greet") {
+					t.Errorf("fix request does not contain the compile log")
+				}
+				if !containsMessage(fix, "assistant", "greet(name)") {
+					t.Errorf("fix request does not contain the previous commands in history")
+				}
+			},
+		},
+		{
+			name: "malformed fix consumes an attempt",
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyCommands(createBadMain).Reply("не JSON").ReplyCommands(fixMain).ReplyCommands(createGoodTest)
+			},
+			wantRequests: 4,
+			check: func(t *testing.T, requests []lmstudiotest.Request) {
+				if !containsMessage(requests[2].Messages, "assistant", "не JSON") {
+					t.Errorf("second fix request does not show the malformed answer")
+				}
+			},
+		},
+		{
+			name: "fix attempts exhausted",
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyCommands(createBadMain).ReplyCommands(stillBadMain).ReplyCommands(stillBadMain)
+			},
+			wantErr:      "не удалось исправить ошибки компиляции за 2 попыток",
+			wantRequests: 3,
+		},
+		{
+			name: "fix request fails",
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyCommands(createBadMain).ReplyStatus(http.StatusInternalServerError, "boom")
+			},
+			wantErr:      "ошибка получения исправления",
+			wantRequests: 2,
+		},
+		{
+			name: "test compile error fixed",
+			script: func(s *lmstudiotest.Server) {
+				s.ReplyCommands(createGoodMain).ReplyCommands(createBadTest).ReplyCommands(fixTest)
+			},
+			wantRequests: 3,
+			check: func(t *testing.T, requests []lmstudiotest.Request) {
+				fix := requests[2].Messages
+				if !strings.Contains(fix[len(fix)-1].Content, "prog/greeting_test.go") {
+					t.Errorf("test fix request is not about the test file")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSandbox(t)
+			srv := lmstudiotest.NewServer(t)
+			tt.script(srv)
+			cfg := domen.Config{
+				Endpoint:              srv.Endpoint(),
+				MaxTaskAttempts:       1,
+				MaxCompileFixAttempts: 2,
+				MaxTestAttempts:       2,
+			}
+
+			err := processTask(t.Context(), greetingTask, cfg)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("processTask() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("processTask() error = %v, want containing %q", err, tt.wantErr)
+			}
+			requests := srv.Requests()
+			if len(requests) != tt.wantRequests {
+				t.Errorf("processTask() made %d requests, want %d", len(requests), tt.wantRequests)
+			}
+			if srv.Pending() != 0 {
+				t.Errorf("processTask() left %d scripted replies unused", srv.Pending())
+			}
+			if tt.check != nil {
+				t.Run("check", func(t *testing.T) { tt.check(t, requests) })
+			}
+		})
+	}
+}
+
+func Test_processTask_workingDir(t *testing.T) {
+	// процесс остаётся в своём каталоге, проект лежит в другом
+	newSandbox(t)
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "go.mod"), []byte("module project\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv := lmstudiotest.NewServer(t)
+	srv.ReplyCommands(domen.Command{Type: "create", Path: "gen/main.go", Content: goodMainCode}).
+		ReplyCommands(domen.Command{Type: "create", Path: "gen/greeting_test.go", Content: goodTestCode})
+	cfg := domen.Config{Endpoint: srv.Endpoint(), WorkingDir: project, OutputDir: "gen"}
+
+	if err := processTask(t.Context(), greetingTask, cfg); err != nil {
+		t.Fatalf("processTask() error = %v", err)
+	}
+	for _, name := range []string{"main.go", "greeting_test.go"} {
+		if _, err := os.Stat(filepath.Join(project, "gen", name)); err != nil {
+			t.Errorf("%s is not created in the project: %v", name, err)
+		}
+	}
+	if !containsMessage(srv.Requests()[0].Messages, "system", "gen/main.go") {
+		t.Errorf("system prompt does not use the configured output dir")
+	}
+}
+
+func TestRunOrchestrator_interrupt(t *testing.T) {
+	newSandbox(t)
+	tasksFile := filepath.Join(t.TempDir(), "tasks.txt")
+	task := greetingTask
+	task.Status = domen.StatusNew
+	if _, err := AppendTask(tasksFile, task); err != nil {
+		t.Fatal(err)
+	}
+	mainPath := filepath.Join("prog", "main.go")
+	if err := os.MkdirAll("prog", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainPath, []byte(goodMainCode), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Модель ломает main.go и создаёт новый файл, а запрос на исправление
+	// прерывается, пока модель «думает».
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	srv := lmstudiotest.NewServer(t).
+		ReplyCommands(stillBadMain, domen.Command{Type: "create", Path: "prog/extra.go", Content: "package main\n"}).
+		ReplyHang(cancel)
+	cfg := domen.Config{TasksFilePath: tasksFile, Endpoint: srv.Endpoint()}
+
+	err := RunOrchestrator(ctx, cfg, domen.TaskFilter{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunOrchestrator() error = %v, want context.Canceled", err)
+	}
+	if data, _ := os.ReadFile(mainPath); string(data) != goodMainCode {
+		t.Errorf("main.go is not rolled back:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join("prog", "extra.go")); !os.IsNotExist(err) {
+		t.Errorf("new file is not removed: %v", err)
+	}
+	task, err = FindTask(tasksFile, task.Num)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != domen.StatusNew {
+		t.Errorf("task status = %s, want new", task.Status)
+	}
+}
+
+func Test_checkLMStudioAvailable(t *testing.T) {
+	srv := lmstudiotest.NewServer(t)
+	if err := checkLMStudioAvailable(t.Context(), srv.Endpoint()); err != nil {
+		t.Errorf("checkLMStudioAvailable() error = %v", err)
+	}
+	endpoint := srv.Endpoint()
+	srv.Close()
+	if err := checkLMStudioAvailable(t.Context(), endpoint); err == nil {
+		t.Errorf("checkLMStudioAvailable() on closed server returned nil")
+	}
+}
+
+// containsMessage сообщает, есть ли сообщение с ролью role, содержащее text.
+func containsMessage(messages []lmstudiotest.Message, role, text string) bool {
+	for _, m := range messages {
+		if m.Role == role && strings.Contains(m.Content, text) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"Ralf/domen"
+	"Ralf/internal/i18n"
 	"bufio"
 	"errors"
 	"fmt"
@@ -10,12 +11,32 @@ import (
 	"strings"
 )
 
+// ErrNoNewTasks возвращается GetNewTask, когда в файле не осталось задач со статусом new.
+var ErrNoNewTasks = i18n.Error("не найдено задач со статусом new")
+
 // GetNewTask читает файл задач и возвращает первую задачу со статусом new.
 // Если задача не найдена или произошла ошибка ввода-вывода, возвращается соответствующая ошибка.
 func GetNewTask(path string) (domen.Task, error) {
+	tasks, err := ReadTasks(path)
+	if err != nil {
+		return domen.Task{}, err
+	}
+
+	// Поиск первой задачи со статусом new
+	for _, task := range tasks {
+		if task.Status == domen.StatusNew {
+			return task, nil
+		}
+	}
+
+	return domen.Task{}, ErrNoNewTasks
+}
+
+// ReadTasks читает и разбирает все задачи из файла в порядке следования.
+func ReadTasks(path string) ([]domen.Task, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return domen.Task{}, fmt.Errorf("не удалось открыть файл задач: %w", err)
+		return nil, fmt.Errorf(i18n.T("не удалось открыть файл задач: %w"), err)
 	}
 	defer file.Close()
 
@@ -45,7 +66,7 @@ func GetNewTask(path string) (domen.Task, error) {
 			if err != nil {
 				// При ошибке парсинга одной задачи прерываем выполнение,
 				// так как файл может быть повреждён.
-				return domen.Task{}, fmt.Errorf("ошибка парсинга задачи: %w", err)
+				return nil, fmt.Errorf(i18n.T("ошибка парсинга задачи: %w"), err)
 			}
 			tasks = append(tasks, task)
 			continue
@@ -66,17 +87,74 @@ func GetNewTask(path string) (domen.Task, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return domen.Task{}, fmt.Errorf("ошибка чтения файла: %w", err)
+		return nil, fmt.Errorf(i18n.T("ошибка чтения файла: %w"), err)
 	}
+	return tasks, nil
+}
 
-	// Поиск первой задачи со статусом new
+// FindTask возвращает задачу с номером num.
+func FindTask(path string, num int) (domen.Task, error) {
+	tasks, err := ReadTasks(path)
+	if err != nil {
+		return domen.Task{}, err
+	}
 	for _, task := range tasks {
-		if task.Status == domen.StatusNew {
+		if task.Num == num {
 			return task, nil
 		}
 	}
+	return domen.Task{}, fmt.Errorf(i18n.T("задача № %d не найдена в файле %s"), num, path)
+}
 
-	return domen.Task{}, errors.New("не найдено задач со статусом new")
+// NextTask возвращает первую задачу, подходящую под фильтр и не входящую в skip
+// (задачи, уже обработанные в текущем запуске). Если таких нет — ErrNoNewTasks.
+func NextTask(path string, filter domen.TaskFilter, skip map[int]bool) (domen.Task, error) {
+	tasks, err := ReadTasks(path)
+	if err != nil {
+		return domen.Task{}, err
+	}
+	for _, task := range tasks {
+		if !skip[task.Num] && filter.Match(task) {
+			return task, nil
+		}
+	}
+	return domen.Task{}, ErrNoNewTasks
+}
+
+// ValidateTasks проверяет файл задач: формат, уникальность номеров, допустимые
+// статусы, наличие описания и ссылки на родительские задачи. Возвращает все
+// найденные проблемы.
+func ValidateTasks(path string) error {
+	tasks, err := ReadTasks(path)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	seen := make(map[int]bool)
+	exists := make(map[int]bool, len(tasks))
+	for _, task := range tasks {
+		exists[task.Num] = true
+	}
+	for _, task := range tasks {
+		if task.Num <= 0 {
+			errs = append(errs, fmt.Errorf(i18n.T("задача без номера или с номером %d"), task.Num))
+			continue
+		}
+		if seen[task.Num] {
+			errs = append(errs, fmt.Errorf(i18n.T("номер задачи %d повторяется"), task.Num))
+		}
+		seen[task.Num] = true
+		if !task.Status.Valid() {
+			errs = append(errs, fmt.Errorf(i18n.T("задача № %d: недопустимый статус %q"), task.Num, task.Status))
+		}
+		if strings.TrimSpace(task.Description) == "" {
+			errs = append(errs, fmt.Errorf(i18n.T("задача № %d: пустое описание"), task.Num))
+		}
+		if task.Parent != 0 && !exists[task.Parent] {
+			errs = append(errs, fmt.Errorf(i18n.T("задача № %d: нет родительской задачи № %d"), task.Num, task.Parent))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // parseTaskFromMap преобразует набор пар «ключ-значение» в структуру Task.
@@ -90,7 +168,7 @@ func parseTaskFromMap(data map[string]string) (domen.Task, error) {
 		case "номер задачи":
 			num, convErr := strconv.Atoi(value)
 			if convErr != nil {
-				return domen.Task{}, fmt.Errorf("неверный формат номера задачи: %w", convErr)
+				return domen.Task{}, fmt.Errorf(i18n.T("неверный формат номера задачи: %w"), convErr)
 			}
 			task.Num = num
 		case "описание задачи":
@@ -105,6 +183,18 @@ func parseTaskFromMap(data map[string]string) (domen.Task, error) {
 			task.FuncSignature = value
 		case "статус выполнения":
 			task.Status = domen.TaskStatus(value)
+		case "родительская задача":
+			parent, convErr := strconv.Atoi(value)
+			if convErr != nil {
+				return domen.Task{}, fmt.Errorf(i18n.T("неверный формат номера родительской задачи: %w"), convErr)
+			}
+			task.Parent = parent
+		case "использовано попыток":
+			attempts, convErr := strconv.Atoi(value)
+			if convErr != nil {
+				return domen.Task{}, fmt.Errorf(i18n.T("неверный формат числа попыток: %w"), convErr)
+			}
+			task.Attempts = attempts
 		default:
 			// Неизвестные ключи игнорируются, что позволяет расширять формат без поломки парсера
 		}
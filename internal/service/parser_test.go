@@ -2,6 +2,9 @@ package service
 
 import (
 	"Ralf/domen"
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -60,3 +63,81 @@ func TestGetNewTask(t *testing.T) {
 		})
 	}
 }
+
+func TestNextTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  domen.TaskFilter
+		skip    map[int]bool
+		wantNum int
+		wantErr error
+	}{
+		{name: "first new", wantNum: 1},
+		{name: "skip attempted", skip: map[int]bool{1: true}, wantNum: 2},
+		{name: "only task", filter: domen.TaskFilter{Num: 3}, wantNum: 3},
+		{name: "from", filter: domen.TaskFilter{From: 2}, wantNum: 2},
+		{name: "no status match", filter: domen.TaskFilter{Statuses: []domen.TaskStatus{domen.StatusError}}, wantErr: ErrNoNewTasks},
+		{name: "all skipped", skip: map[int]bool{1: true, 2: true, 3: true}, wantErr: ErrNoNewTasks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextTask("three_task.txt", tt.filter, tt.skip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NextTask() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Num != tt.wantNum {
+				t.Errorf("NextTask() num = %d, want %d", got.Num, tt.wantNum)
+			}
+		})
+	}
+}
+
+func TestValidateTasks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			content: "начало задачи:\nномер задачи:1\nописание задачи:a\nстатус выполнения:new\nконец задачи.\n",
+		},
+		{
+			name: "duplicate number",
+			content: "начало задачи:\nномер задачи:1\nописание задачи:a\nстатус выполнения:new\nконец задачи.\n" +
+				"начало задачи:\nномер задачи:1\nописание задачи:b\nстатус выполнения:new\nконец задачи.\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad status",
+			content: "начало задачи:\nномер задачи:1\nописание задачи:a\nстатус выполнения:done\nконец задачи.\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty description",
+			content: "начало задачи:\nномер задачи:1\nстатус выполнения:new\nконец задачи.\n",
+			wantErr: true,
+		},
+		{
+			name: "subtask",
+			content: "начало задачи:\nномер задачи:1\nописание задачи:a\nстатус выполнения:run\nконец задачи.\n" +
+				"начало задачи:\nномер задачи:2\nописание задачи:b\nстатус выполнения:new\nродительская задача:1\nконец задачи.\n",
+		},
+		{
+			name:    "missing parent",
+			content: "начало задачи:\nномер задачи:2\nописание задачи:b\nстатус выполнения:new\nродительская задача:1\nконец задачи.\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tasks.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidateTasks(path); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTasks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
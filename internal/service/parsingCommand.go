@@ -2,9 +2,13 @@ package service
 
 import (
 	"Ralf/domen"
+	"Ralf/internal/i18n"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -12,7 +16,21 @@ import (
 // domen.Command уже имеет поля и json-теги, соответствующие выводу LLM.
 func ParseCommands(response string) ([]domen.Command, error) {
 
-	// Убираем возможные markdown-обёртки (```json ... ```)
+	response = stripCodeFence(response)
+	var commands []domen.Command
+	if err := json.Unmarshal([]byte(response), &commands); err != nil {
+		return nil, fmt.Errorf(i18n.T("ошибка парсинга JSON: %w"), err)
+	}
+
+	if len(commands) == 0 {
+		return nil, errors.New(i18n.T("в ответе LM Studio не обнаружено ни одной команды"))
+	}
+	slog.Debug(i18n.T("Разобраны команды модели"), "count", len(commands))
+	return commands, nil
+}
+
+// stripCodeFence убирает возможную markdown-обёртку ответа (```json ... ```).
+func stripCodeFence(response string) string {
 	response = strings.TrimSpace(response)
 	if strings.HasPrefix(response, "```") {
 		lines := strings.Split(response, "\n")
@@ -20,19 +38,73 @@ func ParseCommands(response string) ([]domen.Command, error) {
 			response = strings.Join(lines[1:len(lines)-1], "\n")
 		}
 	}
-	fmt.Println("Начинаем парсинг JSON.")
-	var commands []domen.Command
-	if err := json.Unmarshal([]byte(response), &commands); err != nil {
-		//fmt.Println("--------------")
-		//fmt.Printf("Текст: %s\n", response)
-		//fmt.Println("--------------")
-		return nil, fmt.Errorf("ошибка парсинга JSON: %w", err)
+	return response
+}
+
+// linesEntry находит пары вида 12:"текст строки" (кавычки внутри экранируются).
+var linesEntry = regexp.MustCompile(`(\d+)\s*:\s*"((?:[^"\\]|\\.)*)"`)
+
+// parseLinesMap разбирает строку вида 1:"первая", 2:"вторая" в карту номер → текст.
+func parseLinesMap(s string) map[int]string {
+	result := make(map[int]string)
+	for _, m := range linesEntry.FindAllStringSubmatch(s, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		text, err := strconv.Unquote(`"` + m[2] + `"`)
+		if err != nil {
+			text = m[2]
+		}
+		result[n] = text
 	}
+	return result
+}
 
-	if len(commands) == 0 {
-		return nil, errors.New("в ответе LM Studio не обнаружено ни одной команды")
+// russianTypeSynonyms — русские синонимы, которые модели присылают вместо точных типов.
+var russianTypeSynonyms = map[string]domen.CommandType{
+	"изменение": domen.CmdEdit,
+	"изменить":  domen.CmdEdit,
+}
+
+// mapRussianType сопоставляет русское название типа команды с domen.CommandType.
+func mapRussianType(typStr string) (domen.CommandType, bool) {
+	typStr = strings.ToLower(strings.TrimSpace(typStr))
+	for _, t := range domen.CommandTypes {
+		if string(t) == typStr {
+			return t, true
+		}
 	}
-	fmt.Printf("Количество полученных команд: %d\n", len(commands))
-	//fmt.Println(commands)
-	return commands, nil
+	t, ok := russianTypeSynonyms[typStr]
+	return t, ok
+}
+
+// mapEnglishType сопоставляет английское название типа команды с domen.CommandType.
+// Допускаются варианты написания через пробел или дефис: "add lines", "add-lines".
+func mapEnglishType(typStr string) (domen.CommandType, bool) {
+	typStr = strings.ToLower(strings.TrimSpace(typStr))
+	typStr = strings.NewReplacer(" ", "_", "-", "_").Replace(typStr)
+	for t, name := range domen.EnglishCommandTypes {
+		if name == typStr {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// mapCommandType определяет тип команды по русскому или английскому названию
+// независимо от выбранного языка.
+func mapCommandType(typStr string) (domen.CommandType, bool) {
+	if t, ok := mapRussianType(typStr); ok {
+		return t, true
+	}
+	return mapEnglishType(typStr)
+}
+
+// commandTypeName возвращает название типа команды на текущем языке.
+func commandTypeName(t domen.CommandType) string {
+	if i18n.Current() == i18n.English {
+		return domen.EnglishCommandTypes[t]
+	}
+	return string(t)
 }
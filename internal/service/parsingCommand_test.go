@@ -149,3 +149,33 @@ func Test_parseLinesMap(t *testing.T) {
 		})
 	}
 }
+
+func Test_mapCommandType(t *testing.T) {
+	tests := []struct {
+		typStr string
+		want   domen.CommandType
+		ok     bool
+	}{
+		{typStr: "создание", want: domen.CmdCreate, ok: true},
+		{typStr: "изменение", want: domen.CmdEdit, ok: true},
+		{typStr: "create", want: domen.CmdCreate, ok: true},
+		{typStr: "Delete", want: domen.CmdDelete, ok: true},
+		{typStr: "edit", want: domen.CmdEdit, ok: true},
+		{typStr: "copy", want: domen.CmdCopy, ok: true},
+		{typStr: "move", want: domen.CmdMove, ok: true},
+		{typStr: "read", want: domen.CmdRead, ok: true},
+		{typStr: "compile", want: domen.CmdCompileCode, ok: true},
+		{typStr: "add_lines", want: domen.CmdAddLines, ok: true},
+		{typStr: "add lines", want: domen.CmdAddLines, ok: true},
+		{typStr: "delete-lines", want: domen.CmdDeleteLines, ok: true},
+		{typStr: "rename", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typStr, func(t *testing.T) {
+			got, ok := mapCommandType(tt.typStr)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("mapCommandType() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
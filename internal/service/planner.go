@@ -0,0 +1,152 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"Ralf/internal/prompts"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Режимы планирования (domen.Config.Plan).
+const (
+	PlanOff = "off" // задача решается целиком
+	PlanOn  = "on"  // задача верхнего уровня сначала разбивается на подзадачи
+)
+
+// StagePlan — этап разбиения задачи на подзадачи. В сводке этапов отчёта
+// не участвует: подзадачи проходят обычные этапы.
+const StagePlan = "plan"
+
+// runPlanned выполняет задачу с учётом cfg.Plan. Без планирования, для
+// подзадач и для задач, которые модель не стала дробить, это просто runTask.
+// Иначе задача разбивается на подзадачи (они дописываются в файл задач со
+// ссылкой на родителя), и подзадачи выполняются по порядку обычным конвейером.
+// Задача считается решённой, только когда решены все подзадачи; на первой
+// неудачной обработка останавливается. При повторном запуске используется уже
+// записанный план, а решённые подзадачи пропускаются.
+func runPlanned(ctx context.Context, task domen.Task, cfg domen.Config, changes *ChangeSet, report *TaskReport, runTokens *TokenBudget) error {
+	cfg = cfg.WithDefaults()
+	if cfg.Plan != PlanOn || task.Parent != 0 {
+		return runTask(ctx, task, cfg, changes, report, runTokens)
+	}
+	subtasks, err := planTask(ctx, task, cfg, report, runTokens)
+	if err != nil {
+		return err
+	}
+	if len(subtasks) == 0 {
+		return runTask(ctx, task, cfg, changes, report, runTokens)
+	}
+
+	log := slog.With(logging.KeyTask, task.Num)
+	for _, sub := range subtasks {
+		report.subtask(sub.Num)
+		if sub.Status == domen.StatusOK {
+			continue
+		}
+		log.Info(i18n.T("Выполняем подзадачу"), "subtask", sub.Num, "description", sub.Description)
+		if err := UpdateTaskStatus(cfg.TasksFilePath, sub.Num, domen.StatusRun); err != nil {
+			return fmt.Errorf(i18n.T("не удалось обновить статус run: %w"), err)
+		}
+		subChanges := NewChangeSet()
+		err := runTask(ctx, sub, cfg, subChanges, report, runTokens)
+		changes.Merge(subChanges)
+		status := domen.StatusOK
+		switch {
+		case ctx.Err() != nil:
+			status = domen.StatusNew
+		case err != nil:
+			status = domen.StatusError
+		}
+		if statusErr := UpdateTaskStatus(cfg.TasksFilePath, sub.Num, status); statusErr != nil {
+			err = errors.Join(err, statusErr)
+		}
+		if err != nil {
+			return fmt.Errorf(i18n.T("подзадача %d не выполнена: %w"), sub.Num, err)
+		}
+	}
+	return nil
+}
+
+// planTask возвращает подзадачи задачи: уже записанные в файл задач или,
+// если их нет, полученные от модели и дописанные в файл. Пустой результат —
+// модель решила, что задачу не нужно дробить.
+func planTask(ctx context.Context, task domen.Task, cfg domen.Config, report *TaskReport, runTokens *TokenBudget) ([]domen.Task, error) {
+	tasks, err := ReadTasks(cfg.TasksFilePath)
+	if err != nil {
+		return nil, err
+	}
+	var subtasks []domen.Task
+	for _, t := range tasks {
+		if t.Parent == task.Num {
+			subtasks = append(subtasks, t)
+		}
+	}
+	if len(subtasks) > 0 {
+		return subtasks, nil
+	}
+
+	client, err := NewLLMClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.Log = client.Log.With(logging.KeyTask, task.Num)
+	client.PromptLog = client.PromptLog.With(logging.KeyTask, task.Num)
+	client.Report = report
+	client.Tokens = NewTokenBudget(i18n.T("задачу"), cfg.MaxTaskTokens, runTokens)
+	client.Stage = StagePlan
+
+	planCtx, cancel := withTimeout(ctx, cfg.StageTimeout, fmt.Sprintf(i18n.T("этап %s"), StagePlan))
+	defer cancel()
+	planned, err := client.Plan(planCtx, task, cfg.MaxSubtasks)
+	if err != nil {
+		return nil, budgetError(planCtx, fmt.Errorf(i18n.T("ошибка планирования задачи: %w"), err))
+	}
+	if len(planned) < 2 {
+		client.Log.Info(i18n.T("Модель не стала разбивать задачу на подзадачи"))
+		return nil, nil
+	}
+
+	for _, sub := range planned {
+		sub.Num = 0
+		sub.Status = domen.StatusNew
+		sub.Parent = task.Num
+		written, err := AppendTask(cfg.TasksFilePath, sub)
+		if err != nil {
+			return nil, fmt.Errorf(i18n.T("не удалось записать подзадачу: %w"), err)
+		}
+		client.Log.Info(i18n.T("Подзадача добавлена в план"), "subtask", written.Num, "description", written.Description)
+		subtasks = append(subtasks, written)
+	}
+	return subtasks, nil
+}
+
+// Plan просит модель разбить задачу не больше чем на maxSubtasks подзадач.
+// Ответ — JSON-массив задач в формате domen.Task (без номеров и статусов);
+// подзадачи без описания отбрасываются.
+func (c *LLMClient) Plan(ctx context.Context, task domen.Task, maxSubtasks int) ([]domen.Task, error) {
+	prompt, err := c.Prompts.Plan(prompts.PlanData{Task: task, MaxSubtasks: maxSubtasks})
+	if err != nil {
+		return nil, err
+	}
+	output, err := c.chat(ctx, []Message{{Role: "user", Content: prompt}}, 0.0)
+	if err != nil {
+		return nil, err
+	}
+	var subtasks []domen.Task
+	if err := json.Unmarshal([]byte(stripCodeFence(output)), &subtasks); err != nil {
+		return nil, fmt.Errorf(i18n.T("ошибка парсинга JSON: %w"), err)
+	}
+	planned := subtasks[:0]
+	for _, sub := range subtasks {
+		if strings.TrimSpace(sub.Description) != "" {
+			planned = append(planned, sub)
+		}
+	}
+	return planned, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_runPlanned(t *testing.T) {
+	const twoSteps = `[{"Description": "функция Greeting"}, {"Description": "тесты Greeting", "FuncSignature": "func Greeting(name string) string"}]`
+	tests := []struct {
+		name         string
+		script       func(s *lmstudiotest.Server)
+		wantErr      string
+		wantStatuses map[int]domen.TaskStatus // статусы задач в файле после запуска
+		wantSubtasks []int
+	}{
+		{
+			name: "all subtasks pass",
+			script: func(s *lmstudiotest.Server) {
+				s.Reply(twoSteps)
+				s.ReplyCommands(createGoodMain).ReplyCommands(createGoodTest) // подзадача 2
+				s.ReplyCommands(fixMain).ReplyCommands(fixTest)               // подзадача 3
+			},
+			wantStatuses: map[int]domen.TaskStatus{1: domen.StatusRun, 2: domen.StatusOK, 3: domen.StatusOK},
+			wantSubtasks: []int{2, 3},
+		},
+		{
+			name: "failed subtask stops the plan",
+			script: func(s *lmstudiotest.Server) {
+				s.Reply(twoSteps)
+				s.ReplyStatus(http.StatusInternalServerError, "boom")
+			},
+			wantErr:      "подзадача 2 не выполнена",
+			wantStatuses: map[int]domen.TaskStatus{1: domen.StatusRun, 2: domen.StatusError, 3: domen.StatusNew},
+			wantSubtasks: []int{2},
+		},
+		{
+			name: "single step runs the task itself",
+			script: func(s *lmstudiotest.Server) {
+				s.Reply(`[{"Description": "всё сразу"}]`)
+				s.ReplyCommands(createGoodMain).ReplyCommands(createGoodTest)
+			},
+			wantStatuses: map[int]domen.TaskStatus{1: domen.StatusRun},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSandbox(t)
+			srv := lmstudiotest.NewServer(t)
+			tt.script(srv)
+			if _, err := AppendTask("tasks.txt", greetingTask); err != nil {
+				t.Fatal(err)
+			}
+			report := NewTaskReport(greetingTask)
+
+			cfg := domen.Config{Endpoint: srv.Endpoint(), MaxTaskAttempts: 1, Plan: PlanOn}
+			err := runPlanned(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("runPlanned() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("runPlanned() error = %v, want containing %q", err, tt.wantErr)
+			}
+			if srv.Pending() != 0 {
+				t.Errorf("runPlanned() left %d scripted replies unused", srv.Pending())
+			}
+
+			tasks, err := ReadTasks("tasks.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses := make(map[int]domen.TaskStatus)
+			for _, task := range tasks {
+				statuses[task.Num] = task.Status
+				if task.Num != 1 && task.Parent != 1 {
+					t.Errorf("subtask %d parent = %d, want 1", task.Num, task.Parent)
+				}
+			}
+			if !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("task statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+			if !reflect.DeepEqual(report.Subtasks, tt.wantSubtasks) {
+				t.Errorf("report subtasks = %v, want %v", report.Subtasks, tt.wantSubtasks)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// taskResult — итог задачи, выполненной в отдельной копии проекта.
+type taskResult struct {
+	task    domen.Task
+	dir     string   // корень копии проекта
+	changes []string // затронутые файлы относительно корня копии (в слэш-нотации)
+	report  *TaskReport
+	skipped bool // задача не начата: исчерпан бюджет токенов запуска
+	err     error
+}
+
+// runParallel выполняет задачи, подходящие под фильтр, одновременно — не больше
+// cfg.Workers сразу. Каждая задача работает в своей копии проекта, снятой
+// с общего исходного состояния. Результаты вливаются в проект строго в порядке
+// задач. Задача, чьи файлы уже изменила предыдущая или после вливания которой
+// проект не собирается, могла зависеть от результата предыдущих задач: она
+// выполняется заново поверх уже влитых изменений, и только конфликт после
+// повтора даёт статус error.
+// При отмене ctx ещё не влитые задачи возвращаются в статус new, как и задачи,
+// до которых не дошла очередь после исчерпания бюджета токенов запуска.
+func runParallel(ctx context.Context, cfg domen.Config, ws Workspace, filter domen.TaskFilter, run *RunReport, runTokens *TokenBudget) error {
+	var tasks []domen.Task
+	attempted := make(map[int]bool)
+	for {
+		task, err := NextTask(cfg.TasksFilePath, filter, attempted)
+		if errors.Is(err, ErrNoNewTasks) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf(i18n.T("ошибка получения задачи: %w"), err)
+		}
+		attempted[task.Num] = true
+		tasks = append(tasks, task)
+	}
+
+	// Исходное состояние проекта: с ним сравниваются результаты при вливании
+	base, err := os.MkdirTemp("", "ralf-base-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(base)
+	if err := copyTree(ws.Root, base, ws.Excluded...); err != nil {
+		return fmt.Errorf(i18n.T("не удалось скопировать проект: %w"), err)
+	}
+
+	slog.Info(i18n.T("Параллельная обработка задач"), "tasks", len(tasks), "workers", cfg.Workers)
+	results := make([]chan taskResult, len(tasks))
+	slots := make(chan struct{}, cfg.Workers)
+	for i, task := range tasks {
+		if err := UpdateTaskStatus(cfg.TasksFilePath, task.Num, domen.StatusRun); err != nil {
+			return fmt.Errorf(i18n.T("не удалось обновить статус run: %w"), err)
+		}
+		results[i] = make(chan taskResult, 1)
+		go func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if err := runTokens.check(); err != nil {
+				results[i] <- taskResult{task: task, skipped: true, err: err}
+				return
+			}
+			results[i] <- runIsolated(ctx, task, cfg, ws, base, runTokens)
+		}()
+	}
+
+	processed := 0
+	interrupted := false
+	var budgetErr error
+	for i := range tasks {
+		res := <-results[i]
+		if res.skipped {
+			budgetErr = res.err
+			if err := UpdateTaskStatus(cfg.TasksFilePath, res.task.Num, domen.StatusNew); err != nil {
+				return fmt.Errorf(i18n.T("не удалось обновить статус new: %w"), err)
+			}
+			continue
+		}
+		err := ctx.Err()
+		if err == nil {
+			err = mergeResult(ctx, cfg, ws, base, res)
+		}
+		if res.dir != "" {
+			_ = os.RemoveAll(res.dir)
+		}
+		var conflict *mergeConflict
+		if errors.As(err, &conflict) && ctx.Err() == nil {
+			slog.Warn(i18n.T("Конфликт при вливании, задача выполняется заново поверх влитых изменений"),
+				logging.KeyTask, res.task.Num, logging.KeyError, err)
+			res, err = rerunOnMerged(ctx, cfg, ws, res.task, runTokens)
+		}
+		// конфликт при вливании тоже становится итогом задачи
+		res.report.setResult(err)
+		run.Tasks = append(run.Tasks, res.report)
+		status := domen.StatusOK
+		switch {
+		case ctx.Err() != nil:
+			status = domen.StatusNew
+			interrupted = true
+		case err != nil:
+			status = domen.StatusError
+			slog.Error(i18n.T("Задача завершилась ошибкой"), logging.KeyTask, res.task.Num, logging.KeyError, err)
+		default:
+			processed++
+		}
+		if status != domen.StatusNew {
+			if err := UpdateTaskAttempts(cfg.TasksFilePath, res.task.Num, res.report.TaskAttempts); err != nil {
+				return fmt.Errorf(i18n.T("не удалось записать число попыток задачи: %w"), err)
+			}
+		}
+		if err := UpdateTaskStatus(cfg.TasksFilePath, res.task.Num, status); err != nil {
+			return fmt.Errorf(i18n.T("не удалось обновить статус %s: %w"), status, err)
+		}
+	}
+	if interrupted {
+		slog.Warn(i18n.T("Обработка прервана, невлитые задачи возвращены в статус new"))
+		return fmt.Errorf(i18n.T("обработка прервана: %w"), ctx.Err())
+	}
+	if budgetErr != nil {
+		slog.Warn(i18n.T("Бюджет запуска исчерпан, оставшиеся задачи не начаты"), logging.KeyError, budgetErr)
+		return budgetErr
+	}
+	slog.Info(i18n.T("Все задачи обработаны"), "processed", processed)
+	return nil
+}
+
+// mergeConflict — результат задачи не вливается в текущее состояние проекта.
+type mergeConflict struct {
+	msg string
+}
+
+func (e *mergeConflict) Error() string { return e.msg }
+
+// rerunOnMerged повторяет задачу в копии текущего состояния проекта, где уже
+// есть результаты предыдущих задач, и вливает результат. Конфликт повтора —
+// окончательная ошибка задачи.
+func rerunOnMerged(ctx context.Context, cfg domen.Config, ws Workspace, task domen.Task, runTokens *TokenBudget) (taskResult, error) {
+	base, err := os.MkdirTemp("", "ralf-base-")
+	if err != nil {
+		return taskResult{task: task, report: NewTaskReport(task)}, err
+	}
+	defer os.RemoveAll(base)
+	if err := copyTree(ws.Root, base, ws.Excluded...); err != nil {
+		return taskResult{task: task, report: NewTaskReport(task)}, fmt.Errorf(i18n.T("не удалось скопировать проект: %w"), err)
+	}
+	res := runIsolated(ctx, task, cfg, ws, base, runTokens)
+	defer os.RemoveAll(res.dir)
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	return res, mergeResult(ctx, cfg, ws, base, res)
+}
+
+// runIsolated выполняет задачу в свежей копии исходного состояния проекта.
+// Git в копии не используется: коммит делается при вливании.
+func runIsolated(ctx context.Context, task domen.Task, cfg domen.Config, ws Workspace, base string, runTokens *TokenBudget) taskResult {
+	res := taskResult{task: task, report: NewTaskReport(task)}
+	defer func() { res.report.finish(res.err, res.changes) }()
+	dir, err := os.MkdirTemp("", fmt.Sprintf("ralf-task-%d-", task.Num))
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.dir = dir
+	if err := copyTree(base, dir); err != nil {
+		res.err = fmt.Errorf(i18n.T("не удалось скопировать проект: %w"), err)
+		return res
+	}
+
+	taskCfg := cfg
+	taskCfg.WorkingDir = dir
+	taskCfg.SandboxRoot = ""
+	taskCfg.GitMode = GitModeOff
+	changes := NewChangeSet()
+	res.err = runPlanned(ctx, task, taskCfg, changes, res.report, runTokens)
+	for _, p := range changes.Paths() {
+		if rel, err := filepath.Rel(dir, p); err == nil && !strings.HasPrefix(rel, "..") {
+			res.changes = append(res.changes, filepath.ToSlash(rel))
+		}
+	}
+	return res
+}
+
+// mergeResult переносит файлы успешной задачи из её копии в проект.
+func mergeResult(ctx context.Context, cfg domen.Config, ws Workspace, base string, res taskResult) error {
+	if res.err != nil {
+		return res.err
+	}
+	vcs, err := startTaskGit(ws, cfg.GitMode, res.task)
+	if err != nil {
+		return err
+	}
+	merged := NewChangeSet()
+	err = applyResult(ctx, ws, base, res, merged)
+	return vcs.finish(res.task, merged, err)
+}
+
+// applyResult проверяет конфликты и переносит файлы. При ошибке сборки после
+// переноса прежнее содержимое файлов восстанавливается.
+func applyResult(ctx context.Context, ws Workspace, base string, res taskResult, merged *ChangeSet) error {
+	var conflicts []string
+	backup := make(map[string]fileState, len(res.changes))
+	for _, rel := range res.changes {
+		target := filepath.Join(ws.Root, filepath.FromSlash(rel))
+		current, err := readState(target)
+		if err != nil {
+			return err
+		}
+		original, err := readState(filepath.Join(base, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		if !current.equal(original) {
+			conflicts = append(conflicts, rel)
+		}
+		backup[target] = current
+	}
+	if len(conflicts) > 0 {
+		return &mergeConflict{fmt.Sprintf(i18n.T("конфликт: файлы %s уже изменены другой задачей или вручную"), strings.Join(conflicts, ", "))}
+	}
+
+	restore := func() {
+		for target, state := range backup {
+			_ = state.write(target)
+		}
+	}
+	for _, rel := range res.changes {
+		result, err := readState(filepath.Join(res.dir, filepath.FromSlash(rel)))
+		if err != nil {
+			restore()
+			return err
+		}
+		target := filepath.Join(ws.Root, filepath.FromSlash(rel))
+		// журнал запоминает состояние до вливания: по нему git откатывает задачу
+		merged.Add(target)
+		if err := result.write(target); err != nil {
+			restore()
+			return err
+		}
+	}
+
+	if compileLog, err := ws.CompileTests(ctx); err != nil {
+		restore()
+		if ctx.Err() != nil {
+			return err
+		}
+		return &mergeConflict{fmt.Sprintf(i18n.T("конфликт: после вливания задачи проект не собирается:\n%s"), compileLog)}
+	}
+	slog.Info(i18n.T("Задача влита в проект"), logging.KeyTask, res.task.Num, "files", res.changes)
+	return nil
+}
+
+// copyTree копирует дерево каталогов src в dst без каталога .git и путей
+// excluded (относительно src, в слэш-нотации).
+func copyTree(src, dst string, excluded ...string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if path != src && slices.Contains(excluded, filepath.ToSlash(rel)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			if d.Name() == ".git" && path != src {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, info.Mode().Perm())
+		}
+		return nil
+	})
+}
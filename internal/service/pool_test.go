@@ -0,0 +1,99 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_runParallel(t *testing.T) {
+	newSandbox(t)
+	tasksFile := filepath.Join(t.TempDir(), "tasks.txt")
+	for _, task := range []domen.Task{
+		{Description: "alpha: приветствие", FuncSignature: "func Greeting(name string) string"},
+		{Description: "beta: сложение", FuncSignature: "func Add(a, b int) int"},
+		{Description: "gamma: вычитание", FuncSignature: "func Sub(a, b int) int"},
+	} {
+		if _, err := AppendTask(tasksFile, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addCode := "package calc\n\nfunc Add(a, b int) int { return a + b }\n"
+	subCode := "package calc\n\nfunc Sub(a, b int) int { return a - b }\n"
+	testCode := func(pkg, name string) string {
+		return "package " + pkg + "\n\nimport \"testing\"\n\nfunc Test" + name + "(t *testing.T) {}\n"
+	}
+	srv := lmstudiotest.NewServer(t).
+		ReplyCommandsFor("alpha:", domen.Command{Type: "create", Path: "prog/main.go", Content: goodMainCode}).
+		ReplyCommandsFor("alpha:", domen.Command{Type: "create", Path: "prog/greeting_test.go", Content: testCode("main", "Greeting")}).
+		ReplyCommandsFor("beta:", domen.Command{Type: "create", Path: "prog/calc/add.go", Content: addCode}).
+		ReplyCommandsFor("beta:", domen.Command{Type: "create", Path: "prog/calc/add_test.go", Content: testCode("calc", "Add")}).
+		// gamma пишет в тот же файл, что и beta, — при вливании это конфликт,
+		// и gamma выполняется заново поверх результата beta
+		ReplyCommandsFor("gamma:", domen.Command{Type: "create", Path: "prog/calc/add.go", Content: subCode}).
+		ReplyCommandsFor("gamma:", domen.Command{Type: "create", Path: "prog/calc/sub_test.go", Content: testCode("calc", "Sub")}).
+		ReplyCommandsFor("gamma:", domen.Command{Type: "create", Path: "prog/calc/sub.go", Content: subCode}).
+		ReplyCommandsFor("gamma:", domen.Command{Type: "create", Path: "prog/calc/sub_test.go", Content: testCode("calc", "Sub")})
+
+	cfg := domen.Config{
+		TasksFilePath: tasksFile,
+		Endpoint:      srv.Endpoint(),
+		Workers:       3,
+	}
+	if err := RunOrchestrator(t.Context(), cfg, domen.TaskFilter{}); err != nil {
+		t.Fatalf("RunOrchestrator() error = %v", err)
+	}
+	if srv.Pending() != 0 {
+		t.Errorf("RunOrchestrator() left %d scripted replies unused", srv.Pending())
+	}
+
+	tasks, err := ReadTasks(tasksFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []domen.TaskStatus{domen.StatusOK, domen.StatusOK, domen.StatusOK}
+	for i, task := range tasks {
+		if task.Status != want[i] {
+			t.Errorf("task %d status = %s, want %s", task.Num, task.Status, want[i])
+		}
+		if task.Attempts != 1 {
+			t.Errorf("task %d attempts = %d, want 1 in the task file", task.Num, task.Attempts)
+		}
+	}
+
+	for name, content := range map[string]string{
+		"prog/main.go":          goodMainCode,
+		"prog/calc/add.go":      addCode,
+		"prog/calc/add_test.go": testCode("calc", "Add"),
+		"prog/calc/sub.go":      subCode,
+		"prog/calc/sub_test.go": testCode("calc", "Sub"),
+	} {
+		data, err := os.ReadFile(filepath.FromSlash(name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v; want merged content", name, data, err)
+		}
+	}
+}
+
+func Test_copyTree(t *testing.T) {
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a", "b.go"), "package a\n")
+	writeFile(t, filepath.Join(src, ".git", "HEAD"), "ref: refs/heads/main\n")
+	writeFile(t, filepath.Join(src, "cassettes", "key.json"), "{}")
+	dst := t.TempDir()
+
+	if err := copyTree(src, dst, "cassettes"); err != nil {
+		t.Fatalf("copyTree() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "a", "b.go")); err != nil || string(data) != "package a\n" {
+		t.Errorf("copied file = %q, %v", data, err)
+	}
+	for _, skipped := range []string{".git", "cassettes"} {
+		if _, err := os.Stat(filepath.Join(dst, skipped)); !os.IsNotExist(err) {
+			t.Errorf("%s is copied: %v", skipped, err)
+		}
+	}
+}
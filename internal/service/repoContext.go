@@ -0,0 +1,361 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxTreeEntries ограничивает листинг дерева файлов проекта.
+const maxTreeEntries = 300
+
+// declSnippet — экспортируемое объявление из пакета проекта.
+type declSnippet struct {
+	File  string          // путь к файлу, где найдено объявление
+	Text  string          // объявление без тел функций
+	Words map[string]bool // слова из идентификаторов объявления
+	Score int             // пересечение со словами задачи
+}
+
+// BuildRepoContext собирает контекст существующего проекта для задачи:
+// листинг файлов в root, go.mod и экспортируемые объявления из пакетов рядом
+// с целью задачи; outputDir — целевой каталог задачи относительно root.
+// Объявления отбираются по пересечению идентификаторов с текстом задачи.
+// Файлы и каталоги excluded (пути относительно root) в листинг не попадают.
+// Результат укладывается в budget токенов.
+func BuildRepoContext(root, outputDir string, task domen.Task, budget int, counter TokenCounter, excluded ...string) string {
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return ""
+	}
+
+	var sb strings.Builder
+	used := 0
+	// add добавляет секцию, если она помещается в оставшийся бюджет
+	add := func(section string) bool {
+		tokens := counter.CountTokens(section)
+		if used+tokens > budget {
+			return false
+		}
+		sb.WriteString(section)
+		used += tokens
+		return true
+	}
+
+	tree := fileTree(root, excluded)
+	if len(tree) == 0 {
+		return ""
+	}
+	add(i18n.T("Текущее состояние проекта (пути от корня проекта):") + "\n")
+	for len(tree) > 0 && !add(i18n.T("Файлы:")+"\n"+strings.Join(tree, "\n")+"\n\n") {
+		tree = tree[:len(tree)/2]
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, "go.mod")); err == nil {
+		add("go.mod:\n" + string(data) + "\n")
+	}
+
+	taskWords := identWords(strings.Join([]string{
+		task.Description, task.ImportantInfo, task.ExpectResult, task.FuncSignature,
+	}, " "))
+	var snippets []declSnippet
+	for _, dir := range neighbourPackages(root, outputDir, task) {
+		snippets = append(snippets, packageDecls(dir)...)
+	}
+	for i := range snippets {
+		for w := range snippets[i].Words {
+			if taskWords[w] {
+				snippets[i].Score++
+			}
+		}
+	}
+	sort.SliceStable(snippets, func(i, j int) bool { return snippets[i].Score > snippets[j].Score })
+
+	header := false
+	for _, s := range snippets {
+		if s.Score == 0 {
+			break
+		}
+		if !header {
+			if !add(i18n.T("Объявления из соседних пакетов:") + "\n") {
+				break
+			}
+			header = true
+		}
+		file := s.File
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+		add(fmt.Sprintf("// %s\n%s\n\n", filepath.ToSlash(file), s.Text))
+	}
+	return sb.String()
+}
+
+// fileTree возвращает отсортированный список файлов проекта без скрытых каталогов
+// и путей excluded. Пути даются относительно root — в таком виде их используют
+// команды модели.
+func fileTree(root string, excluded []string) []string {
+	var files []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if path != root && slices.Contains(excluded, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && len(files) < maxTreeEntries {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+// taskPath находит в тексте задачи пути вида internal/config/loader.go.
+var taskPath = regexp.MustCompile(`(?:[\w.-]+/)+[\w.-]+`)
+
+// neighbourPackages возвращает каталоги пакетов рядом с целью задачи: сам каталог
+// цели, его родителя и соседние каталоги. Если цель не указана в тексте задачи —
+// пакеты целевого каталога outputDir, а если их нет — все пакеты проекта.
+func neighbourPackages(root, outputDir string, task domen.Task) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	addDir := func(dir string) {
+		if seen[dir] {
+			return
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	text := strings.Join([]string{task.Description, task.ImportantInfo, task.ExpectResult}, " ")
+	prefix := filepath.ToSlash(filepath.Clean(root)) + "/"
+	for _, p := range taskPath.FindAllString(text, -1) {
+		p = strings.TrimPrefix(strings.TrimRight(p, "."), prefix)
+		target := filepath.Join(root, filepath.FromSlash(p))
+		if filepath.Ext(target) != "" {
+			target = filepath.Dir(target)
+		}
+		addDir(target)
+		parent := filepath.Dir(target)
+		addDir(parent)
+		if entries, err := os.ReadDir(parent); err == nil {
+			for _, e := range entries {
+				if e.IsDir() {
+					addDir(filepath.Join(parent, e.Name()))
+				}
+			}
+		}
+	}
+	if len(dirs) > 0 {
+		return dirs
+	}
+
+	if outputDir != "" && outputDir != "." {
+		dirs = goPackageDirs(filepath.Join(root, filepath.FromSlash(outputDir)))
+		if len(dirs) > 0 {
+			return dirs
+		}
+	}
+	return goPackageDirs(root)
+}
+
+// goPackageDirs возвращает каталоги внутри dir с .go-файлами, кроме скрытых
+// каталогов и vendor.
+func goPackageDirs(dir string) []string {
+	var dirs []string
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != dir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor") {
+			return filepath.SkipDir
+		}
+		if matches, _ := filepath.Glob(filepath.Join(path, "*.go")); len(matches) > 0 {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs
+}
+
+// packageDecls разбирает .go-файлы каталога (без тестов) и возвращает
+// экспортируемые объявления без тел функций.
+func packageDecls(dir string) []declSnippet {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	fset := token.NewFileSet()
+	var snippets []declSnippet
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			node, idents := exportedDecl(decl)
+			if node == nil {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := format.Node(&buf, fset, node); err != nil {
+				continue
+			}
+			words := identWords(strings.Join(append(idents, file.Name.Name), " "))
+			snippets = append(snippets, declSnippet{File: path, Text: buf.String(), Words: words})
+		}
+	}
+	return snippets
+}
+
+// exportedDecl возвращает копию объявления только с экспортируемыми частями
+// и имена объявленных идентификаторов. Для неэкспортируемых объявлений — nil.
+func exportedDecl(decl ast.Decl) (ast.Node, []string) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if !d.Name.IsExported() || !exportedReceiver(d) {
+			return nil, nil
+		}
+		fn := *d
+		fn.Body = nil
+		fn.Doc = nil
+		return &fn, []string{d.Name.Name}
+	case *ast.GenDecl:
+		if d.Tok == token.IMPORT {
+			return nil, nil
+		}
+		var specs []ast.Spec
+		var idents []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				if s.Name.IsExported() {
+					specs = append(specs, s)
+					idents = append(idents, s.Name.Name)
+				}
+			case *ast.ValueSpec:
+				exported := false
+				for _, n := range s.Names {
+					if n.IsExported() {
+						exported = true
+						idents = append(idents, n.Name)
+					}
+				}
+				if exported {
+					specs = append(specs, s)
+				}
+			}
+		}
+		if len(specs) == 0 {
+			return nil, nil
+		}
+		gen := *d
+		gen.Doc = nil
+		gen.Specs = specs
+		if len(specs) == 1 {
+			gen.Lparen = token.NoPos
+		}
+		return &gen, idents
+	}
+	return nil, nil
+}
+
+// exportedReceiver сообщает, что у метода экспортируемый тип-получатель (или это функция).
+func exportedReceiver(fn *ast.FuncDecl) bool {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return true
+	}
+	expr := fn.Recv.List[0].Type
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.IsExported()
+		default:
+			return false
+		}
+	}
+}
+
+// identifier выделяет идентификаторы из произвольного текста.
+var identifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// identWords разбивает идентификаторы текста на слова в нижнем регистре
+// (LoadConfig → load, config; http_server → http, server). Короткие слова отбрасываются.
+func identWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, ident := range identifier.FindAllString(text, -1) {
+		for _, w := range splitIdent(ident) {
+			if len(w) >= 3 {
+				words[strings.ToLower(w)] = true
+			}
+		}
+	}
+	return words
+}
+
+// splitIdent разбивает идентификатор по camelCase и подчёркиваниям.
+func splitIdent(ident string) []string {
+	var words []string
+	var current []rune
+	runes := []rune(ident)
+	for i, r := range runes {
+		if r == '_' {
+			if len(current) > 0 {
+				words = append(words, string(current))
+				current = nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				words = append(words, string(current))
+				current = nil
+			}
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		words = append(words, string(current))
+	}
+	return words
+}
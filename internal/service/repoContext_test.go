@@ -0,0 +1,137 @@
+package service
+
+import (
+	"Ralf/domen"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_splitIdent(t *testing.T) {
+	tests := []struct {
+		ident string
+		want  []string
+	}{
+		{ident: "LoadConfig", want: []string{"Load", "Config"}},
+		{ident: "HTTPServer", want: []string{"HTTP", "Server"}},
+		{ident: "http_server", want: []string{"http", "server"}},
+		{ident: "x", want: []string{"x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ident, func(t *testing.T) {
+			if got := splitIdent(tt.ident); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitIdent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildRepoContext(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "prog")
+	files := map[string]string{
+		"go.mod": "module telegram-bot-service\n\ngo 1.23\n",
+		"internal/config/config.go": `package config
+
+// Config — конфигурация.
+type Config struct {
+	TelegramToken string
+	port          int
+}
+
+func LoadConfig(path string) (Config, error) {
+	return Config{}, nil
+}
+
+func helper() {}
+`,
+		"internal/storage/storage.go": `package storage
+
+type Storage interface {
+	Save(key string) error
+}
+
+func NewStorage() Storage { return nil }
+`,
+		"internal/config/config_test.go": "package config\n\nfunc TestLoadConfig() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	task := domen.Task{
+		Num:           4,
+		Description:   "Implement YAML configuration loader in internal/config/loader.go.",
+		ImportantInfo: "Reuse Config struct.",
+		FuncSignature: "func LoadConfig(path string) (Config, error)",
+	}
+
+	got := BuildRepoContext(root, ".", task, 100000, testCounter)
+	for _, want := range []string{
+		"internal/config/config.go",
+		"module telegram-bot-service",
+		"func LoadConfig(path string) (Config, error)",
+		"TelegramToken string",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("BuildRepoContext() missing %q in:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"func helper", "return Config{}", "NewStorage"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("BuildRepoContext() contains %q in:\n%s", unwanted, got)
+		}
+	}
+
+	small := BuildRepoContext(root, ".", task, 60, testCounter)
+	if testCounter.CountTokens(small) > 60 {
+		t.Errorf("BuildRepoContext() exceeds budget: %d tokens", testCounter.CountTokens(small))
+	}
+
+	if got := BuildRepoContext(filepath.Join(root, "missing"), ".", task, 100000, testCounter); got != "" {
+		t.Errorf("BuildRepoContext() for missing root = %q, want empty", got)
+	}
+}
+
+func Test_neighbourPackages(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"app/main.go", "internal/config/config.go", "internal/storage/storage.go", "docs/readme.md"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name      string
+		outputDir string
+		text      string
+		want      []string
+	}{
+		{name: "path in the task", outputDir: "app", text: "Extend internal/config/loader.go", want: []string{"internal/config", "internal", "internal/storage"}},
+		{name: "output dir", outputDir: "internal", want: []string{"internal/config", "internal/storage"}},
+		{name: "output dir without packages", outputDir: "docs", want: []string{"app", "internal/config", "internal/storage"}},
+		{name: "project root", outputDir: ".", want: []string{"app", "internal/config", "internal/storage"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, dir := range neighbourPackages(root, tt.outputDir, domen.Task{Description: tt.text}) {
+				rel, _ := filepath.Rel(root, dir)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("neighbourPackages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Этапы обработки задачи — ключи в отчёте о запуске.
+const (
+	StageSolve       = "solve"        // решение задачи моделью и применение команд
+	StageCompile     = "compile"      // сборка с циклом исправлений
+	StageTests       = "tests"        // генерация тестов и применение команд
+	StageTestCompile = "test_compile" // сборка тестов с циклом исправлений
+	StageCoverage    = "coverage"     // измерение покрытия и дополнение тестов
+	StageLint        = "lint"         // проверки качества с циклом исправлений
+	StageReview      = "review"       // ревью решения моделью с доработками
+)
+
+// Stages — этапы в порядке выполнения.
+var Stages = []string{StageSolve, StageCompile, StageTests, StageTestCompile, StageCoverage, StageLint, StageReview}
+
+// StageReport — итог одного этапа задачи. Для этапов сборки Attempts — число
+// запросов на исправление, для остальных — число запросов к модели.
+type StageReport struct {
+	Attempts int  `json:"attempts"`
+	Passed   bool `json:"passed"`
+}
+
+// TaskReport — итог задачи в отчёте о запуске. Методы безопасны для nil,
+// поэтому этапы вызывают их, не проверяя, ведётся ли отчёт.
+type TaskReport struct {
+	Num              int                     `json:"num"`
+	Description      string                  `json:"description"`
+	Status           domen.TaskStatus        `json:"status"`
+	Stages           map[string]*StageReport `json:"stages"`
+	TaskAttempts     int                     `json:"task_attempts"`
+	DurationSec      float64                 `json:"duration_sec"`
+	LLMCalls         int                     `json:"llm_calls"`
+	PromptTokens     int                     `json:"prompt_tokens"`
+	CompletionTokens int                     `json:"completion_tokens"`
+	FilesChanged     []string                `json:"files_changed"`
+	CompileOK        bool                    `json:"compile_ok"`
+	TestsOK          bool                    `json:"tests_ok"`           // тесты прошли в последнем запуске go test (сборка тестов — в этапе test_compile)
+	Coverage         *float64                `json:"coverage,omitempty"` // покрытие тестами изменённых файлов, %
+	LastError        string                  `json:"last_error,omitempty"`
+	SolvedBy         string                  `json:"solved_by,omitempty"` // модель последнего успешного запроса решённой задачи (любого этапа)
+	Subtasks         []int                   `json:"subtasks,omitempty"`  // номера подзадач, если задача решалась по плану
+	Review           *ReviewVerdict          `json:"review,omitempty"`    // последнее заключение ревьюера
+	Findings         []string                `json:"findings,omitempty"`  // замечания анализаторов с политикой report
+
+	mu        sync.Mutex // защищает счётчики запросов: кандидаты решения запрашиваются одновременно
+	started   time.Time
+	lastModel string // модель последнего успешного запроса
+}
+
+// NewTaskReport начинает отчёт по задаче и засекает время.
+func NewTaskReport(task domen.Task) *TaskReport {
+	return &TaskReport{
+		Num:         task.Num,
+		Description: task.Description,
+		Status:      domen.StatusRun,
+		Stages:      make(map[string]*StageReport),
+		started:     time.Now(),
+	}
+}
+
+func (r *TaskReport) stage(name string) *StageReport {
+	s, ok := r.Stages[name]
+	if !ok {
+		s = &StageReport{}
+		r.Stages[name] = s
+	}
+	return s
+}
+
+// newAttempt отмечает начало попытки задачи. Попытки этапов копятся по всем
+// попыткам задачи, а пройденными считаются этапы последней.
+func (r *TaskReport) newAttempt() {
+	if r == nil {
+		return
+	}
+	r.TaskAttempts++
+	for _, s := range r.Stages {
+		s.Passed = false
+	}
+	r.CompileOK, r.TestsOK = false, false
+	r.Coverage = nil
+	r.Findings = nil
+}
+
+// subtask отмечает подзадачу из плана задачи.
+func (r *TaskReport) subtask(num int) {
+	if r == nil {
+		return
+	}
+	r.Subtasks = append(r.Subtasks, num)
+}
+
+// review запоминает заключение ревьюера.
+func (r *TaskReport) review(v ReviewVerdict) {
+	if r == nil {
+		return
+	}
+	r.Review = &v
+}
+
+// coverage запоминает измеренное покрытие тестами.
+func (r *TaskReport) coverage(percent float64) {
+	if r == nil {
+		return
+	}
+	r.Coverage = &percent
+}
+
+// finding добавляет замечание анализатора.
+func (r *TaskReport) finding(d string) {
+	if r == nil {
+		return
+	}
+	r.Findings = append(r.Findings, d)
+}
+
+// start отмечает, что задача дошла до этапа.
+func (r *TaskReport) start(stage string) {
+	if r == nil {
+		return
+	}
+	r.stage(stage)
+}
+
+// attempt отмечает попытку на этапе.
+func (r *TaskReport) attempt(stage string) {
+	if r == nil {
+		return
+	}
+	r.stage(stage).Attempts++
+}
+
+// pass отмечает успешное завершение этапа.
+func (r *TaskReport) pass(stage string) {
+	if r == nil {
+		return
+	}
+	r.stage(stage).Passed = true
+	if stage == StageCompile {
+		r.CompileOK = true
+	}
+}
+
+// testRun фиксирует итог последнего запуска тестов задачи.
+func (r *TaskReport) testRun(passed bool) {
+	if r == nil {
+		return
+	}
+	r.TestsOK = passed
+}
+
+// llmCall учитывает запрос к модели model и израсходованные токены.
+func (r *TaskReport) llmCall(model string, usage chatUsage) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastModel = model
+	r.LLMCalls++
+	r.PromptTokens += usage.PromptTokens
+	r.CompletionTokens += usage.CompletionTokens
+}
+
+// finish фиксирует итог задачи: статус, время, последнюю ошибку и изменённые файлы.
+func (r *TaskReport) finish(err error, files []string) {
+	if r == nil {
+		return
+	}
+	r.DurationSec = time.Since(r.started).Seconds()
+	r.FilesChanged = files
+	r.setResult(err)
+}
+
+// setResult выставляет статус задачи по её итоговой ошибке.
+func (r *TaskReport) setResult(err error) {
+	if r == nil {
+		return
+	}
+	r.Status = domen.StatusOK
+	r.LastError = ""
+	r.SolvedBy = ""
+	if errors.Is(err, context.Canceled) {
+		// прерванная задача возвращается в очередь
+		r.Status = domen.StatusNew
+		r.LastError = err.Error()
+		return
+	}
+	if err != nil {
+		r.Status = domen.StatusError
+		r.LastError = err.Error()
+		return
+	}
+	r.SolvedBy = r.lastModel
+}
+
+// StageSummary — доля задач, прошедших этап, среди дошедших до него.
+type StageSummary struct {
+	Stage    string  `json:"stage"`
+	Reached  int     `json:"reached"`
+	Passed   int     `json:"passed"`
+	PassRate float64 `json:"pass_rate"`
+}
+
+// RunReport — отчёт о запуске оркестратора.
+type RunReport struct {
+	Started          time.Time      `json:"started"`
+	Finished         time.Time      `json:"finished"`
+	Model            string         `json:"model"`
+	Endpoint         string         `json:"endpoint"`
+	Workers          int            `json:"workers"`
+	Tasks            []*TaskReport  `json:"tasks"`
+	Summary          []StageSummary `json:"summary"`
+	LLMCalls         int            `json:"llm_calls"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+}
+
+// NewRunReport начинает отчёт о запуске.
+func NewRunReport(cfg domen.Config) *RunReport {
+	return &RunReport{Started: time.Now(), Model: cfg.Model, Endpoint: cfg.Endpoint, Workers: cfg.Workers}
+}
+
+// summarize подводит итоги по этапам и общий расход токенов.
+func (r *RunReport) summarize() {
+	r.Finished = time.Now()
+	r.Summary = r.Summary[:0]
+	r.LLMCalls, r.PromptTokens, r.CompletionTokens = 0, 0, 0
+	for _, stage := range Stages {
+		s := StageSummary{Stage: stage}
+		for _, t := range r.Tasks {
+			if st, ok := t.Stages[stage]; ok {
+				s.Reached++
+				if st.Passed {
+					s.Passed++
+				}
+			}
+		}
+		if s.Reached > 0 {
+			s.PassRate = float64(s.Passed) / float64(s.Reached)
+		}
+		r.Summary = append(r.Summary, s)
+	}
+	for _, t := range r.Tasks {
+		r.LLMCalls += t.LLMCalls
+		r.PromptTokens += t.PromptTokens
+		r.CompletionTokens += t.CompletionTokens
+	}
+}
+
+// Write сохраняет отчёт в dir в двух видах: report-<время>.json и report-<время>.md.
+// Возвращает пути к файлам.
+func (r *RunReport) Write(dir string) (string, string, error) {
+	r.summarize()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf(i18n.T("не удалось создать каталог отчётов: %w"), err)
+	}
+	base := filepath.Join(dir, "report-"+r.Started.Format("20060102-150405"))
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return "", "", fmt.Errorf(i18n.T("не удалось записать отчёт: %w"), err)
+	}
+	if err := os.WriteFile(base+".md", []byte(r.Markdown()), 0644); err != nil {
+		return "", "", fmt.Errorf(i18n.T("не удалось записать отчёт: %w"), err)
+	}
+	return base + ".json", base + ".md", nil
+}
+
+// Markdown возвращает отчёт в виде Markdown для описания PR.
+func (r *RunReport) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, i18n.T("# Отчёт Ralf от %s\n\n"), r.Started.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&sb, i18n.T("Модель: `%s`, задач: %d, вызовов LLM: %d, токенов: %d запрос / %d ответ.\n\n"),
+		r.Model, len(r.Tasks), r.LLMCalls, r.PromptTokens, r.CompletionTokens)
+
+	sb.WriteString(i18n.T("## Этапы") + "\n\n")
+	sb.WriteString(i18n.T("| Этап | Дошли | Прошли | Доля |") + "\n|---|---|---|---|\n")
+	for _, s := range r.Summary {
+		fmt.Fprintf(&sb, "| %s | %d | %d | %.0f%% |\n", s.Stage, s.Reached, s.Passed, s.PassRate*100)
+	}
+
+	sb.WriteString("\n" + i18n.T("## Задачи") + "\n\n")
+	fmt.Fprintf(&sb, i18n.T("| № | Статус | Попытки (%s) | Попытки задачи | Время, с | LLM | Токены | Сборка | Тесты | Покрытие | Модель | Файлы |"), strings.Join(Stages, "/"))
+	sb.WriteString("\n|---|---|---|---|---|---|---|---|---|---|---|---|\n")
+	for _, t := range r.Tasks {
+		attempts := make([]string, 0, len(Stages))
+		for _, stage := range Stages {
+			if s, ok := t.Stages[stage]; ok {
+				attempts = append(attempts, fmt.Sprint(s.Attempts))
+			} else {
+				attempts = append(attempts, "–")
+			}
+		}
+		coverage := "–"
+		if t.Coverage != nil {
+			coverage = fmt.Sprintf("%.1f%%", *t.Coverage)
+		}
+		fmt.Fprintf(&sb, "| %d | %s | %s | %d | %.1f | %d | %d | %s | %s | %s | %s | %s |\n",
+			t.Num, t.Status, strings.Join(attempts, "/"), t.TaskAttempts, t.DurationSec, t.LLMCalls,
+			t.PromptTokens+t.CompletionTokens, mark(t.CompileOK), mark(t.TestsOK), coverage, t.SolvedBy, strings.Join(t.FilesChanged, ", "))
+	}
+
+	var linted []*TaskReport
+	for _, t := range r.Tasks {
+		if len(t.Findings) > 0 {
+			linted = append(linted, t)
+		}
+	}
+	if len(linted) > 0 {
+		sb.WriteString("\n" + i18n.T("## Замечания анализаторов") + "\n")
+		for _, t := range linted {
+			fmt.Fprintf(&sb, i18n.T("\n### Задача %d\n\n"), t.Num)
+			for _, f := range t.Findings {
+				fmt.Fprintf(&sb, "- `%s`\n", f)
+			}
+		}
+	}
+
+	var failed []*TaskReport
+	for _, t := range r.Tasks {
+		if t.LastError != "" {
+			failed = append(failed, t)
+		}
+	}
+	if len(failed) > 0 {
+		sb.WriteString("\n" + i18n.T("## Ошибки") + "\n")
+		for _, t := range failed {
+			fmt.Fprintf(&sb, i18n.T("\n### Задача %d\n\n```\n%s\n```\n"), t.Num, t.LastError)
+		}
+	}
+	return sb.String()
+}
+
+func mark(ok bool) string {
+	if ok {
+		return "✅"
+	}
+	return "❌"
+}
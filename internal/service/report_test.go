@@ -0,0 +1,134 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_runTask_report(t *testing.T) {
+	newSandbox(t)
+	srv := lmstudiotest.NewServer(t)
+	srv.ReplyCommands(createBadMain).ReplyCommands(fixMain).ReplyCommands(createGoodTest)
+	report := NewTaskReport(greetingTask)
+	changes := NewChangeSet()
+
+	cfg := domen.Config{Endpoint: srv.Endpoint()}
+	ws, err := NewWorkspace(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runTask(t.Context(), greetingTask, cfg, changes, report, nil)
+	report.finish(err, ws.Rel(changes.Paths()))
+	if err != nil {
+		t.Fatalf("runTask() error = %v", err)
+	}
+
+	want := map[string]StageReport{
+		StageSolve:       {Attempts: 1, Passed: true},
+		StageCompile:     {Attempts: 1, Passed: true},
+		StageTests:       {Attempts: 1, Passed: true},
+		StageTestCompile: {Attempts: 0, Passed: true},
+	}
+	for stage, w := range want {
+		if got := report.Stages[stage]; got == nil || *got != w {
+			t.Errorf("stage %s = %+v, want %+v", stage, got, w)
+		}
+	}
+	if report.LLMCalls != 3 || report.PromptTokens == 0 || report.CompletionTokens == 0 {
+		t.Errorf("LLM usage = %d calls, %d/%d tokens", report.LLMCalls, report.PromptTokens, report.CompletionTokens)
+	}
+	if !report.CompileOK || !report.TestsOK || report.Status != domen.StatusOK {
+		t.Errorf("result = compile %v, tests %v, status %s", report.CompileOK, report.TestsOK, report.Status)
+	}
+	if strings.Join(report.FilesChanged, ",") != "prog/greeting_test.go,prog/main.go" {
+		t.Errorf("FilesChanged = %v", report.FilesChanged)
+	}
+}
+
+func Test_runTask_report_testsFail(t *testing.T) {
+	newSandbox(t)
+	failingTest := strings.Replace(goodTestCode, `"Hello, World!"`, `"Hi"`, 1)
+	srv := lmstudiotest.NewServer(t)
+	createFailingTest := domen.Command{Type: "create", Path: "prog/greeting_test.go", Content: failingTest}
+	editFailingTest := domen.Command{Type: "edit", Path: "prog/greeting_test.go", Content: failingTest}
+	srv.ReplyCommands(createGoodMain).ReplyCommands(createFailingTest).ReplyCommands(editFailingTest)
+	report := NewTaskReport(greetingTask)
+
+	cfg := domen.Config{Endpoint: srv.Endpoint(), MaxTaskAttempts: 1, MaxCoverageRounds: 1}
+	if err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil); err == nil {
+		t.Fatal("runTask() with failing tests: want error")
+	}
+
+	// тесты собрались, но не прошли: это видно по tests_ok, а не по этапу сборки тестов
+	if got := report.Stages[StageTestCompile]; got == nil || !got.Passed {
+		t.Errorf("test compile stage = %+v, want passed", got)
+	}
+	if report.TestsOK {
+		t.Errorf("TestsOK = true for failing tests")
+	}
+}
+
+func TestRunReport_Write(t *testing.T) {
+	ok := NewTaskReport(domen.Task{Num: 1, Description: "сложение"})
+	ok.attempt(StageSolve)
+	ok.pass(StageSolve)
+	ok.start(StageCompile)
+	ok.pass(StageCompile)
+	ok.finish(nil, []string{"prog/add.go"})
+
+	failed := NewTaskReport(domen.Task{Num: 2, Description: "вычитание"})
+	failed.attempt(StageSolve)
+	failed.pass(StageSolve)
+	failed.start(StageCompile)
+	failed.attempt(StageCompile)
+	failed.attempt(StageCompile)
+	failed.finish(errors.New("undefined: sub"), nil)
+
+	run := NewRunReport(domen.Config{Model: "qwen"})
+	run.Tasks = []*TaskReport{ok, failed}
+	dir := filepath.Join(t.TempDir(), "reports")
+	jsonPath, mdPath, err := run.Write(dir)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got RunReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	wantSummary := []StageSummary{
+		{Stage: StageSolve, Reached: 2, Passed: 2, PassRate: 1},
+		{Stage: StageCompile, Reached: 2, Passed: 1, PassRate: 0.5},
+		{Stage: StageTests},
+		{Stage: StageTestCompile},
+	}
+	for i, w := range wantSummary {
+		if i >= len(got.Summary) || got.Summary[i] != w {
+			t.Errorf("Summary = %+v, want %+v", got.Summary, wantSummary)
+			break
+		}
+	}
+	if got.Tasks[1].Status != domen.StatusError || got.Tasks[1].LastError != "undefined: sub" || got.Tasks[1].Stages[StageCompile].Attempts != 2 {
+		t.Errorf("failed task = %+v", got.Tasks[1])
+	}
+
+	md, err := os.ReadFile(mdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"| compile | 2 | 1 | 50% |", "| 2 | error | 1/2/–/–/–/–/– |", "undefined: sub", "prog/add.go"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("Markdown report does not contain %q:\n%s", want, md)
+		}
+	}
+}
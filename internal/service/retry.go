@@ -0,0 +1,35 @@
+package service
+
+import (
+	"Ralf/domen"
+	"context"
+	"errors"
+)
+
+// prevFailureLines — сколько строк причины неудачи попадает в запрос повторной попытки.
+const prevFailureLines = 40
+
+// retryable сообщает, имеет ли смысл повторять задачу после ошибки err.
+// Отмена и исчерпанные лимиты времени и токенов не повторяются: новая
+// попытка упрётся в них же.
+func retryable(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == nil && !errors.Is(err, ErrBudgetExceeded)
+}
+
+// prepareAttempt настраивает клиента на попытку attempt (с единицы) после
+// неудачи prevErr: каждая повторная попытка повышает температуру на
+// cfg.RetryTemperatureStep, берёт очередную модель из cfg.RetryModels
+// (последняя используется и дальше) для этапов без цепочки моделей
+// и показывает модели причину неудачи.
+func (c *LLMClient) prepareAttempt(cfg domen.Config, attempt int, prevErr error) {
+	if attempt <= 1 {
+		return
+	}
+	// отрицательный шаг выключает повышение температуры
+	c.TempBoost = float64(attempt-1) * max(cfg.RetryTemperatureStep, 0)
+	if models := ParseModelChain(cfg.RetryModels); len(models) > 0 {
+		c.base = models[min(attempt-2, len(models)-1)]
+		c.useModel(c.base)
+	}
+	c.PrevFailure = truncateLog(prevErr.Error(), prevFailureLines)
+}
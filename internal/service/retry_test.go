@@ -0,0 +1,106 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_runTask_retry(t *testing.T) {
+	newSandbox(t)
+	srv := lmstudiotest.NewServer(t)
+	srv.ReplyCommands(createBadMain).ReplyCommands(stillBadMain)    // попытка 1: сборка не исправлена
+	srv.ReplyCommands(createGoodMain).ReplyCommands(createGoodTest) // попытка 2
+	report := NewTaskReport(greetingTask)
+
+	cfg := domen.Config{
+		Endpoint:              srv.Endpoint(),
+		MaxTaskAttempts:       2,
+		MaxCompileFixAttempts: 1,
+		RetryModels:           " big-model ,",
+	}
+	err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil)
+	report.finish(err, nil)
+	if err != nil {
+		t.Fatalf("runTask() error = %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("runTask() made %d requests, want 4", len(requests))
+	}
+	first, retry := requests[0], requests[2]
+	if first.Model != "local-model" || first.Temperature != 0 {
+		t.Errorf("first attempt = model %q, temperature %v", first.Model, first.Temperature)
+	}
+	if retry.Model != "big-model" || retry.Temperature != 0.2 {
+		t.Errorf("retry = model %q, temperature %v, want big-model, 0.2", retry.Model, retry.Temperature)
+	}
+	if len(retry.Messages) != 2 {
+		t.Errorf("retry sent %d messages, want system and task only (fresh history)", len(retry.Messages))
+	}
+	if !strings.Contains(retry.Messages[len(retry.Messages)-1].Content, "undefined: greet") {
+		t.Errorf("retry prompt does not contain the previous failure")
+	}
+
+	if report.TaskAttempts != 2 || report.Status != domen.StatusOK {
+		t.Errorf("report = %d task attempts, status %s", report.TaskAttempts, report.Status)
+	}
+	if got := report.Stages[StageCompile]; got == nil || *got != (StageReport{Attempts: 1, Passed: true}) {
+		t.Errorf("compile stage = %+v", got)
+	}
+	data, err := os.ReadFile("prog/main.go")
+	if err != nil || string(data) != goodMainCode {
+		t.Errorf("prog/main.go = %q, %v; want the code of the last attempt", data, err)
+	}
+}
+
+func Test_retryable(t *testing.T) {
+	canceled, cancel := context.WithCancel(t.Context())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{name: "success", ctx: t.Context(), err: nil, want: false},
+		{name: "stage error", ctx: t.Context(), err: errors.New("undefined: greet"), want: true},
+		{name: "budget", ctx: t.Context(), err: fmt.Errorf("этап: %w", ErrBudgetExceeded), want: false},
+		{name: "canceled", ctx: canceled, err: errors.New("прервано"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLLMClient_prepareAttempt(t *testing.T) {
+	prevErr := errors.New("undefined: greet")
+	tests := []struct {
+		name string
+		cfg  domen.Config
+		want float64
+	}{
+		{name: "default step", cfg: domen.Config{}.WithDefaults(), want: 0.4},
+		{name: "negative step disables the boost", cfg: domen.Config{RetryTemperatureStep: -1}.WithDefaults(), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c LLMClient
+			c.prepareAttempt(tt.cfg, 3, prevErr)
+			if math.Abs(c.TempBoost-tt.want) > 1e-9 {
+				t.Errorf("TempBoost = %v, want %v", c.TempBoost, tt.want)
+			}
+		})
+	}
+}
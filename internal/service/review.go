@@ -0,0 +1,279 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"Ralf/internal/prompts"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Режимы ревью решения (domen.Config.Review).
+const (
+	ReviewOff = "off" // задача считается решённой, когда собираются код и тесты
+	ReviewOn  = "on"  // перед статусом ok решение проверяет модель-ревьюер
+)
+
+// reviewDiffLines — сколько строк diff отправляется ревьюеру.
+const reviewDiffLines = 600
+
+// diffContextLines — строки контекста вокруг изменений в unified diff.
+const diffContextLines = 3
+
+// ReviewVerdict — заключение ревьюера по решению задачи.
+type ReviewVerdict struct {
+	Requirements []ReviewRequirement `json:"requirements"`
+	Risks        []string            `json:"risks,omitempty"` // рискованные места: паники, гонки, необработанные ошибки
+	Style        []string            `json:"style,omitempty"` // замечания по стилю
+}
+
+// ReviewRequirement — одно требование задачи и вывод ревьюера о нём.
+type ReviewRequirement struct {
+	Requirement string `json:"requirement"`
+	Met         bool   `json:"met"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// unmet возвращает невыполненные требования вместе с пояснениями ревьюера.
+func (v ReviewVerdict) unmet() []string {
+	var unmet []string
+	for _, r := range v.Requirements {
+		if r.Met {
+			continue
+		}
+		if r.Comment != "" {
+			unmet = append(unmet, r.Requirement+": "+r.Comment)
+		} else {
+			unmet = append(unmet, r.Requirement)
+		}
+	}
+	return unmet
+}
+
+// reviewLoop отдаёт изменения задачи на ревью. Если ревьюер отметил
+// невыполненные требования, модель дорабатывает решение, recheck снова
+// проводит его через сборку и проверки (pending — синтаксические ошибки
+// доработки), и ревью повторяется — не больше maxFixes доработок.
+// Рискованные места и замечания по стилю только пишутся в журнал и отчёт.
+func reviewLoop(ctx context.Context, client *LLMClient, task domen.Task, history *TaskHistory, maxFixes int, recheck func(ctx context.Context, pending string) error) error {
+	ws := client.Workspace
+	for round := 0; ; round++ {
+		client.Report.attempt(StageReview)
+		verdict, err := client.Review(ctx, task, ws.Changes.Diff(ws.Root))
+		if err != nil {
+			return fmt.Errorf(i18n.T("ошибка ревью решения: %w"), err)
+		}
+		client.Report.review(verdict)
+		for _, risk := range verdict.Risks {
+			client.Log.Warn(i18n.T("Ревью: рискованное место"), "note", risk)
+		}
+		for _, note := range verdict.Style {
+			client.Log.Info(i18n.T("Ревью: замечание по стилю"), "note", note)
+		}
+
+		unmet := verdict.unmet()
+		if len(unmet) == 0 {
+			client.Report.pass(StageReview)
+			return nil
+		}
+		if round >= maxFixes {
+			return fmt.Errorf(i18n.T("ревью: требования не выполнены после %d доработок: %s"), round, strings.Join(unmet, "; "))
+		}
+
+		log := client.Log.With(logging.KeyStage, StageReview, logging.KeyAttempt, round+1)
+		log.Info(i18n.T("Ревью нашло невыполненные требования, дорабатываем"), "unmet", len(unmet))
+		response, err := client.SendReviewFix(ctx, task, round+1, unmet, history)
+		if err != nil {
+			return fmt.Errorf(i18n.T("ошибка получения доработки от LM Studio: %w"), err)
+		}
+		commands, err := ParseCommands(response)
+		if err != nil {
+			log.Warn(i18n.T("Не удалось разобрать исправления"), logging.KeyError, err)
+			continue
+		}
+		var syntax strings.Builder
+		for _, execErr := range client.applyCommands(ctx, commands, &syntax) {
+			log.Warn(i18n.T("Исправление не применилось"), logging.KeyError, execErr)
+		}
+		// доработка проходит те же этапы, что и исходное решение
+		if err := recheck(ctx, syntax.String()); err != nil {
+			return err
+		}
+	}
+}
+
+// Review отправляет ревьюеру задачу и diff её решения. Ответ — JSON-объект
+// ReviewVerdict.
+func (c *LLMClient) Review(ctx context.Context, task domen.Task, diff string) (ReviewVerdict, error) {
+	prompt, err := c.Prompts.Review(prompts.ReviewData{Task: task, Diff: truncateLog(diff, reviewDiffLines)})
+	if err != nil {
+		return ReviewVerdict{}, err
+	}
+	output, err := c.chat(ctx, []Message{{Role: "user", Content: prompt}}, 0.0)
+	if err != nil {
+		return ReviewVerdict{}, err
+	}
+	var verdict ReviewVerdict
+	if err := json.Unmarshal([]byte(stripCodeFence(output)), &verdict); err != nil {
+		return ReviewVerdict{}, fmt.Errorf(i18n.T("ошибка парсинга JSON: %w"), err)
+	}
+	return verdict, nil
+}
+
+// SendReviewFix просит модель доработать решение по невыполненным требованиям.
+// Запрос отправляется вместе с историей задачи; он и ответ модели дописываются в историю.
+func (c *LLMClient) SendReviewFix(ctx context.Context, task domen.Task, round int, unmet []string, history *TaskHistory) (string, error) {
+	system, err := c.systemPrompt()
+	if err != nil {
+		return "", err
+	}
+	prompt, err := c.Prompts.ReviewFix(prompts.ReviewFixData{Task: task, Round: round, Unmet: unmet})
+	if err != nil {
+		return "", err
+	}
+	remaining := c.promptBudget() - countMessagesTokens(c.Tokenizer, []Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: prompt},
+	})
+	messages := []Message{{Role: "system", Content: system}}
+	messages = append(messages, history.Messages(remaining, c.Tokenizer)...)
+	messages = append(messages, Message{Role: "user", Content: prompt})
+
+	response, err := c.chat(ctx, messages, 0.1)
+	if err != nil {
+		return "", err
+	}
+	history.AddDiagnostic(prompt)
+	history.AddCommands(response)
+	return response, nil
+}
+
+// unifiedDiff возвращает изменения файла name между before и after в формате
+// unified diff с diffContextLines строками контекста.
+func unifiedDiff(name string, before, after fileState) string {
+	var sb strings.Builder
+	from, to := "a/"+name, "b/"+name
+	if !before.exists {
+		from = "/dev/null"
+	}
+	if !after.exists {
+		to = "/dev/null"
+	}
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", from, to)
+
+	ops := diffLines(splitLines(before.data), splitLines(after.data))
+	for start := 0; start < len(ops); {
+		// ищем следующее изменение
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// расширяем ханк, пока изменения идут не дальше чем через 2*контекст строк
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContextLines {
+				break
+			}
+		}
+		from := max(start-diffContextLines, 0)
+		to := min(end+diffContextLines, len(ops))
+		oldStart, newStart, oldLen, newLen := 1, 1, 0, 0
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				oldStart++
+			}
+			if op.kind != '-' {
+				newStart++
+			}
+		}
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldLen++
+			}
+			if op.kind != '-' {
+				newLen++
+			}
+		}
+		// пустой диапазон указывает на строку перед ним, как в diff -u
+		if oldLen == 0 {
+			oldStart--
+		}
+		if newLen == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+		for _, op := range ops[from:to] {
+			fmt.Fprintf(&sb, "%c%s\n", op.kind, op.line)
+		}
+		start = to
+	}
+	return sb.String()
+}
+
+// diffOp — строка в сценарии правки: ' ' без изменений, '-' удалена, '+' добавлена.
+type diffOp struct {
+	kind byte
+	line []byte
+}
+
+// maxDiffCells ограничивает размер таблицы в diffLines: файлы больше этого
+// сравниваются целиком — все старые строки удалены, все новые добавлены.
+const maxDiffCells = 4_000_000
+
+// diffLines строит сценарий правки a → b по наибольшей общей подпоследовательности.
+func diffLines(a, b [][]byte) []diffOp {
+	if len(a)*len(b) > maxDiffCells {
+		ops := make([]diffOp, 0, len(a)+len(b))
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+	// lcs[i][j] — длина общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if string(a[i]) == string(b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case string(a[i]) == string(b[j]):
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"strings"
+	"testing"
+)
+
+const (
+	unmetVerdict = `{"Requirements": [{"Requirement": "приветствие с именем", "Met": false, "Comment": "имя не выводится"}]}`
+	metVerdict   = "```json\n" + `{"Requirements": [{"Requirement": "приветствие с именем", "Met": true}], "Risks": ["нет проверки пустого имени"]}` + "\n```"
+)
+
+func Test_runTask_review(t *testing.T) {
+	tests := []struct {
+		name       string
+		verdicts   []string // ответы ревьюера по порядку; между ними — доработки
+		maxFixes   int
+		wantErr    string
+		wantReview StageReport
+	}{
+		{
+			name:       "accepted at once",
+			verdicts:   []string{metVerdict},
+			wantReview: StageReport{Attempts: 1, Passed: true},
+		},
+		{
+			name:       "unmet requirement becomes a fix round",
+			verdicts:   []string{unmetVerdict, metVerdict},
+			wantReview: StageReport{Attempts: 2, Passed: true},
+		},
+		{
+			name:       "fixes run out",
+			verdicts:   []string{unmetVerdict, unmetVerdict},
+			maxFixes:   1,
+			wantErr:    "имя не выводится",
+			wantReview: StageReport{Attempts: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSandbox(t)
+			srv := lmstudiotest.NewServer(t)
+			srv.ReplyCommands(createGoodMain).ReplyCommands(createGoodTest)
+			for i, verdict := range tt.verdicts {
+				if i > 0 {
+					srv.ReplyCommands(fixMain)
+				}
+				srv.Reply(verdict)
+			}
+			report := NewTaskReport(greetingTask)
+
+			cfg := domen.Config{Endpoint: srv.Endpoint(), MaxTaskAttempts: 1, Review: ReviewOn, MaxReviewFixes: tt.maxFixes}
+			err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("runTask() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("runTask() error = %v, want error containing %q", err, tt.wantErr)
+			}
+
+			requests := srv.Requests()
+			review := requests[2].Messages
+			if len(review) != 1 || !strings.Contains(review[0].Content, "+++ b/prog/main.go") {
+				t.Errorf("review request = %+v, want a single prompt with the diff", review)
+			}
+			if len(tt.verdicts) > 1 && !containsMessage(requests[3].Messages, "user", "имя не выводится") {
+				t.Errorf("review fix request does not name the unmet requirement")
+			}
+			if got := report.Stages[StageReview]; got == nil || *got != tt.wantReview {
+				t.Errorf("review stage = %+v, want %+v", got, tt.wantReview)
+			}
+			if report.Review == nil || len(report.Review.Requirements) != 1 {
+				t.Errorf("report review = %+v", report.Review)
+			}
+		})
+	}
+}
+
+func Test_unifiedDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after fileState
+		want          string
+	}{
+		{
+			name:  "new file",
+			after: fileState{data: []byte("a\nb\n"), exists: true},
+			want:  "--- /dev/null\n+++ b/x.go\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:   "edited line keeps context",
+			before: fileState{data: []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n"), exists: true},
+			after:  fileState{data: []byte("1\n2\n3\n4\nпять\n6\n7\n8\n9\n"), exists: true},
+			want:   "--- a/x.go\n+++ b/x.go\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+пять\n 6\n 7\n 8\n",
+		},
+		{
+			name:   "distant edits make two hunks",
+			before: fileState{data: []byte("a\n1\n2\n3\n4\n5\n6\n7\nb\n"), exists: true},
+			after:  fileState{data: []byte("A\n1\n2\n3\n4\n5\n6\n7\nB\n"), exists: true},
+			want:   "--- a/x.go\n+++ b/x.go\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("x.go", tt.before, tt.after); got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_runTask_reviewRechecks(t *testing.T) {
+	newSandbox(t)
+	srv := lmstudiotest.NewServer(t)
+	srv.ReplyCommands(createGoodMain).ReplyCommands(createGoodTest)
+	srv.Reply(unmetVerdict)
+	// доработка по ревью ломает форматирование, которое lint уже принял
+	srv.ReplyCommands(domen.Command{Type: "edit", Path: "prog/main.go", Content: strings.ReplaceAll(goodMainCode, "\t", "  ")})
+	srv.ReplyCommands(fixMain)
+	srv.Reply(metVerdict)
+	report := NewTaskReport(greetingTask)
+
+	cfg := domen.Config{Endpoint: srv.Endpoint(), MaxTaskAttempts: 1, Format: FormatOff, Lint: "gofmt", Review: ReviewOn}
+	if err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil); err != nil {
+		t.Fatalf("runTask() error = %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 6 {
+		t.Fatalf("runTask() made %d requests, want 6", len(requests))
+	}
+	if !containsMessage(requests[4].Messages, "user", "prog/main.go: файл не отформатирован gofmt (gofmt)") {
+		t.Errorf("lint is not re-run after the review fix")
+	}
+	if got := report.Stages[StageLint]; got == nil || *got != (StageReport{Attempts: 1, Passed: true}) {
+		t.Errorf("lint stage = %+v", got)
+	}
+	if got := report.Stages[StageReview]; got == nil || *got != (StageReport{Attempts: 2, Passed: true}) {
+		t.Errorf("review stage = %+v", got)
+	}
+}
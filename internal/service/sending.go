@@ -1,137 +1,239 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"context"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"Ralf/domen"
+	"Ralf/internal/prompts"
 )
 
-const StrictCommandTemplate = `Ты — эксперт-программист Go.
-
-Твоя ЕДИНСТВЕННАЯ задача — выполнять задачу и возвращать ТОЛЬКО валидный JSON-массив объектов.
-
-ОБЯЗАТЕЛЬНО: Используй ТОЧНО такие значения в поле "Type" — без сокращений, без синонимов:
-- "создание" (не "create", не "создать")
-- "удаление" (не "delete", не "удалить")
-- "внесение изменений" (не "изменение", не "edit", не "изменить")
-- "добавление строк" (не "add lines")
-- "удаление строк" (не "delete lines")
-- "копирование" (не "copy")
-- "перемещение" (не "move")
-- "чтение" (не "read")
-- "компиляция" (не "compile")
-
-Пример правильного поля Type:
-"Type": "внесение изменений"   ← именно так, полностью
+// LLMClient управляет взаимодействием с LM Studio через OpenAI-compatible API.
+type LLMClient struct {
+	BaseURL     string
+	Model       string
+	HTTPClient  *http.Client
+	MaxTokens   int          // лимит токенов ответа модели
+	ContextSize int          // размер контекстного окна модели в токенах
+	Tokenizer   TokenCounter // оценка количества токенов в запросе
+	Prompts     *prompts.Set // шаблоны промптов
+	Backend     chatBackend  // транспорт запросов: LM Studio, запись или воспроизведение
+	Workspace   Workspace    // каталоги проекта: пути в промптах и чтение файлов
+	Report      *TaskReport  // отчёт по текущей задаче: вызовы модели и токены (может быть nil)
+	Log         *slog.Logger // основной журнал (с атрибутами задачи)
+	PromptLog   *slog.Logger // журнал полных промптов и ответов модели
+	Stage       string       // текущий этап задачи — атрибут записей журнала
+	Tokens      *TokenBudget // лимит токенов задачи (nil — без ограничения)
+	TempBoost   float64      // прибавка к температуре запросов на повторной попытке задачи
+	PrevFailure string       // причина неудачи предыдущей попытки — попадает в запрос задачи
+
+	Chains        map[string][]ModelRef // цепочки моделей по этапам, от быстрой к мощной
+	EscalateAfter int                   // после скольких неудачных исправлений переходить к следующей модели
+
+	base        ModelRef               // модель этапов без цепочки
+	level       int                    // уровень цепочек моделей, достигнутый в задаче (общий для этапов)
+	endpoint    string                 // основной адрес API
+	backends    map[string]chatBackend // backend по адресу API
+	autoContext bool                   // размер контекста определяется по модели
+}
 
-ВАЖНЕЙШЕЕ ТРЕБОВАНИЕ №1: ВСЕ пути начинаются с prog/
-Примеры: prog/main.go, prog/greeting_test.go
+// NewLLMClient создаёт клиента LM Studio. Незаданные настройки берутся из
+// domen.DefaultConfig. Размер контекста берётся из конфигурации,
+// а если он не задан — из таблицы известных моделей. Шаблоны промптов на языке
+// cfg.Language загружаются из cfg.PromptsDir с откатом на встроенные.
+func NewLLMClient(cfg domen.Config) (*LLMClient, error) {
+	cfg = cfg.WithDefaults()
+	lang, err := i18n.ParseLang(cfg.Language)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := NewWorkspace(cfg)
+	if err != nil {
+		return nil, err
+	}
+	promptSet, err := prompts.Load(cfg.PromptsDir, lang)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.T("не удалось загрузить шаблоны промптов: %w"), err)
+	}
+	c := &LLMClient{
+		BaseURL: strings.TrimRight(cfg.Endpoint, "/"),
+		Model:   cfg.Model,
+		HTTPClient: &http.Client{
+			Timeout: cfg.RequestTimeout,
+		},
+		MaxTokens: 16384,
+		Tokenizer: HeuristicCounter{CharsPerToken: cfg.CharsPerToken},
+		Prompts:   promptSet,
+		Workspace: ws,
+		Log:       slog.Default(),
+		PromptLog: logging.Prompts(),
+	}
+	c.ContextSize = cfg.ContextSize
+	if c.ContextSize <= 0 {
+		c.ContextSize = ModelContextSize(c.Model)
+	}
+	c.Backend, err = newChatBackend(cfg, &httpBackend{BaseURL: c.BaseURL, HTTPClient: c.HTTPClient})
+	if err != nil {
+		return nil, err
+	}
+	c.Chains = stageChains(cfg)
+	c.EscalateAfter = cfg.EscalateAfter
+	c.base = ModelRef{Name: c.Model}
+	c.endpoint = c.BaseURL
+	c.autoContext = cfg.ContextSize <= 0
+	chains := [][]ModelRef{ParseModelChain(cfg.RetryModels)}
+	for _, chain := range c.Chains {
+		chains = append(chains, chain)
+	}
+	if c.backends, err = backendsFor(cfg, c.HTTPClient, chains...); err != nil {
+		return nil, err
+	}
+	c.backends[c.endpoint] = c.Backend
+	return c, nil
+}
 
-ПРАВИЛО №1: Ответ — ТОЛЬКО JSON-массив. Никакого текста, markdown, ±json.
-ПРАВИЛО №2: Пример ответа:
+// enterStage переключает клиента на этап задачи: этап попадает в журнал и отчёт,
+// а запросы идут к модели цепочки этапа на достигнутом в задаче уровне
+// (или к основной модели).
+func (c *LLMClient) enterStage(stage string) {
+	c.Stage = stage
+	if level := c.chainLevel(stage); level >= 0 {
+		c.useModel(c.Chains[stage][level])
+	} else {
+		c.useModel(c.base)
+	}
+	c.Report.start(stage)
+}
 
-[
-{
-"Type": "создание",
-"Path": "prog/main.go",
-"Content": "package main\\n\\nimport \\"fmt\\"\\n\\nfunc Greeting(name string) string {\\n\\tif name == \\"\\" {\\n\\t\\treturn \\"Hello, World!\\"\\n\\t}\\n\\treturn \\"Hello, \\" + name + \\"!\\"\\n}"
+// systemPrompt формирует системный промпт со списком допустимых типов команд
+// на текущем языке.
+func (c *LLMClient) systemPrompt() (string, error) {
+	types := make([]string, 0, len(domen.CommandTypes))
+	for _, t := range domen.CommandTypes {
+		types = append(types, commandTypeName(t))
+	}
+	return c.Prompts.System(prompts.SystemData{OutputDir: c.Workspace.OutputDir, CommandTypes: types})
 }
-]
 
-Выполни задачу и верни ТОЛЬКО JSON-массив.`
+// replyTokens возвращает лимит токенов ответа: не больше половины контекста,
+// чтобы запросу всегда оставалось место.
+func (c *LLMClient) replyTokens() int {
+	return min(c.MaxTokens, c.ContextSize/2)
+}
 
-// LLMClient управляет взаимодействием с LM Studio через OpenAI-compatible API.
-type LLMClient struct {
-	BaseURL    string
-	Model      string
-	HTTPClient *http.Client
+// repoContextBudget возвращает бюджет токенов на контекст проекта в запросе задачи.
+func (c *LLMClient) repoContextBudget() int {
+	return c.promptBudget() / 4
 }
 
-func NewLLMClient() *LLMClient {
-	return &LLMClient{
-		BaseURL: "http://localhost:1234/v1",
-		Model:   "local-model",
-		HTTPClient: &http.Client{
-			Timeout: 300 * time.Second,
-		},
-	}
+// promptBudget возвращает бюджет токенов на запрос (контекст минус резерв под ответ).
+func (c *LLMClient) promptBudget() int {
+	return c.ContextSize - c.replyTokens()
 }
 
 // SendTaskToLLM отправляет структуру Task в LM Studio и возвращает список parsed команд.
-func SendTaskToLLM(task domen.Task) ([]domen.Command, error) {
-	client := NewLLMClient()
-	return client.sendTask(task)
+// Если передана история, запрос и ответ модели сохраняются в неё,
+// а уже накопленная переписка отправляется вместе с запросом.
+func SendTaskToLLM(ctx context.Context, task domen.Task, history *TaskHistory) ([]domen.Command, error) {
+	client, err := NewLLMClient(domen.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return client.SendTask(ctx, task, "", history)
 }
 
-func (c *LLMClient) sendTask(task domen.Task) ([]domen.Command, error) {
-	userPrompt := fmt.Sprintf(`Задача №%d
-
-Описание задачи: %s
-Важные моменты: %s
-Ожидаемый результат: %s
-Тестовые данные: %s
-Сигнатура функции: %s
-
-Реализуй задачу строго по шаблону выше.
-Все файлы должны находиться внутри папки prog/.
-Если нужно создать тесты — используй prog/<имя_функции>_test.go`,
-		task.Num,
-		task.Description,
-		task.ImportantInfo,
-		task.ExpectResult,
-		task.TestsValue,
-		task.FuncSignature)
-
-	reqBody := map[string]any{
-		"model": c.Model,
-		"messages": []map[string]string{
-			{"role": "system", "content": StrictCommandTemplate},
-			{"role": "user", "content": userPrompt},
-		},
-		"temperature": 0.0,
-		"top_p":       1.0,
-		"max_tokens":  16384,
-		"stream":      false,
+// SendTask отправляет задачу в LM Studio вместе с историей, урезанной под бюджет контекста.
+// repoContext — описание существующего проекта (см. BuildRepoContext), может быть пустым.
+func (c *LLMClient) SendTask(ctx context.Context, task domen.Task, repoContext string, history *TaskHistory) ([]domen.Command, error) {
+	messages, userPrompt, err := c.taskMessages(task, repoContext, history)
+	if err != nil {
+		return nil, err
 	}
-	fmt.Println("Начинаем процесс маршалирование запроса от llm.")
-	jsonData, err := json.Marshal(reqBody)
+	llmOutput, err := c.chat(ctx, messages, 0.0)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось маршалировать запрос: %w", err)
+		return nil, err
 	}
-	fmt.Println("Начинаем процесс соединения с LM Studio.")
-	resp, err := c.HTTPClient.Post(c.BaseURL+"/chat/completions", "application/json", bytes.NewReader(jsonData))
+	history.AddPrompt(userPrompt)
+	history.AddCommands(llmOutput)
+	return ParseCommands(llmOutput)
+}
+
+// taskMessages собирает запрос задачи: системный промпт, историю, урезанную
+// под бюджет контекста, и сам промпт задачи, который возвращается отдельно.
+func (c *LLMClient) taskMessages(task domen.Task, repoContext string, history *TaskHistory) ([]Message, string, error) {
+	system, err := c.systemPrompt()
+	if err != nil {
+		return nil, "", err
+	}
+	userPrompt, err := c.Prompts.Task(prompts.TaskData{Task: task, OutputDir: c.Workspace.OutputDir, RepoContext: repoContext, PrevFailure: c.PrevFailure})
 	if err != nil {
-		return nil, fmt.Errorf("ошибка соединения с LM Studio: %w", err)
+		return nil, "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("LM Studio вернул код %d: %s", resp.StatusCode, string(body))
+	remaining := c.promptBudget() - countMessagesTokens(c.Tokenizer, []Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: userPrompt},
+	})
+	messages := []Message{{Role: "system", Content: system}}
+	messages = append(messages, history.Messages(remaining, c.Tokenizer)...)
+	messages = append(messages, Message{Role: "user", Content: userPrompt})
+	return messages, userPrompt, nil
+}
+
+// maxTemperature — верхняя граница температуры в OpenAI-совместимом API.
+const maxTemperature = 2.0
+
+// chat выполняет запрос к /chat/completions через backend и возвращает текст ответа модели.
+// Отмена ctx прерывает запрос.
+func (c *LLMClient) chat(ctx context.Context, messages []Message, temperature float64) (string, error) {
+	if tokens := countMessagesTokens(c.Tokenizer, messages); tokens > c.promptBudget() {
+		c.Log.Warn(i18n.T("Запрос превышает бюджет контекста"), logging.KeyStage, c.Stage, "tokens", tokens, "budget", c.promptBudget())
 	}
 
-	var apiResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+	reqBody := chatRequest{
+		Model:       c.Model,
+		Messages:    messages,
+		Temperature: min(temperature+c.TempBoost, maxTemperature),
+		TopP:        1.0,
+		MaxTokens:   c.replyTokens(),
+		Stream:      false,
 	}
-	fmt.Println("Начинаем парсинг JSON ответа.")
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга JSON ответа: %w", err)
+	if err := c.Tokens.check(); err != nil {
+		return "", err
 	}
+	started := time.Now()
+	apiResp, err := c.Backend.complete(ctx, reqBody)
+	if err != nil {
+		c.PromptLog.Debug("chat", logging.KeyStage, c.Stage, "messages", messages, logging.KeyError, err.Error())
+		return "", err
+	}
+	c.Report.llmCall(c.Model, apiResp.Usage)
 
-	if len(apiResp.Choices) == 0 || apiResp.Choices[0].Message.Content == "" {
-		return nil, errors.New("LM Studio вернул пустой ответ")
+	var content string
+	if len(apiResp.Choices) > 0 {
+		content = apiResp.Choices[0].Message.Content
 	}
-	fmt.Println("Начинаем процесс парсинга команд:")
-	llmOutput := apiResp.Choices[0].Message.Content
-	return ParseCommands(llmOutput)
+	spent := apiResp.Usage.PromptTokens + apiResp.Usage.CompletionTokens
+	if spent == 0 {
+		// сервер не сообщил расход — оцениваем его сами
+		spent = countMessagesTokens(c.Tokenizer, messages) + c.Tokenizer.CountTokens(content)
+	}
+	c.Tokens.spend(spent)
+	c.Log.Debug(i18n.T("Получен ответ модели"), logging.KeyStage, c.Stage,
+		"prompt_tokens", apiResp.Usage.PromptTokens, "completion_tokens", apiResp.Usage.CompletionTokens,
+		"duration", time.Since(started))
+	c.PromptLog.Debug("chat", logging.KeyStage, c.Stage, "model", c.Model, "temperature", reqBody.Temperature,
+		"messages", messages, "response", content,
+		"prompt_tokens", apiResp.Usage.PromptTokens, "completion_tokens", apiResp.Usage.CompletionTokens)
+	if content == "" {
+		return "", errors.New(i18n.T("LM Studio вернул пустой ответ"))
+	}
+	return content, nil
 }
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"context"
+	"errors"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// CheckSyntax — имя проверки в замечаниях о синтаксисе.
+const CheckSyntax = "syntax"
+
+// errSyntax — в командах модели есть .go-файлы с синтаксическими ошибками.
+var errSyntax = i18n.Error("синтаксическая ошибка в коде модели")
+
+// SyntaxError — содержимое .go-файла из команды модели не разбирается;
+// файл при этом не записывается. Written — ошибку нашло форматирование уже
+// записанного файла (после правки отдельных строк).
+type SyntaxError struct {
+	Diagnostics []Diagnostic
+	Written     bool
+}
+
+func (e *SyntaxError) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics)+1)
+	if e.Written {
+		lines = append(lines, i18n.T("синтаксическая ошибка в записанном файле:"))
+	} else {
+		lines = append(lines, i18n.T("синтаксическая ошибка, файл не записан:"))
+	}
+	for _, d := range e.Diagnostics {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkSyntax разбирает содержимое .go-файла из команды создания или полной
+// замены до записи на диск. rel — путь для замечаний. Точечные правки строк
+// не проверяются: их результат разберёт форматирование после записи.
+func checkSyntax(cmd domen.Command, typ domen.CommandType, rel string) error {
+	if typ != domen.CmdCreate && typ != domen.CmdEdit || cmd.Content == "" || !strings.HasSuffix(cmd.Path, ".go") {
+		return nil
+	}
+	_, err := parser.ParseFile(token.NewFileSet(), rel, cmd.Content, parser.SkipObjectResolution)
+	if diags := syntaxDiagnostics(CheckSyntax, rel, err); len(diags) > 0 {
+		return &SyntaxError{Diagnostics: diags}
+	}
+	return nil
+}
+
+// syntaxLog собирает текст синтаксических ошибок из ошибок выполнения команд,
+// чтобы отправить его модели вместо лога сборки. ok = false — ошибка другая.
+func syntaxLog(log *strings.Builder, err error) (ok bool) {
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return false
+	}
+	if log.Len() > 0 {
+		log.WriteString("\n")
+	}
+	log.WriteString(syntaxErr.Error())
+	return true
+}
+
+// applyCommands выполняет команды ответа модели (Workspace.ExecuteAll).
+// Синтаксические ошибки дописываются в syntax, чтобы показать их модели
+// вместо лога сборки; найденные форматированием ещё и попадают в отчёт.
+// Возвращает остальные ошибки команд.
+func (c *LLMClient) applyCommands(ctx context.Context, commands []domen.Command, syntax *strings.Builder) []error {
+	execErrs, format := c.Workspace.ExecuteAll(ctx, commands)
+	var errs []error
+	for _, err := range execErrs {
+		if !syntaxLog(syntax, err) {
+			errs = append(errs, err)
+		}
+	}
+	if len(format) > 0 {
+		for _, d := range format {
+			c.Report.finding(d.String())
+		}
+		syntaxLog(syntax, &SyntaxError{Diagnostics: format, Written: true})
+	}
+	return errs
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/lmstudiotest"
+	"errors"
+	"os"
+	"testing"
+)
+
+const brokenMainCode = "package main\n\nfunc Greeting(name string) string {\n\treturn \"Hello, \" +\n}\n"
+
+func Test_runTask_syntax(t *testing.T) {
+	newSandbox(t)
+	srv := lmstudiotest.NewServer(t)
+	srv.ReplyCommands(domen.Command{Type: "create", Path: "prog/main.go", Content: brokenMainCode})
+	srv.ReplyCommands(createGoodMain).ReplyCommands(createGoodTest)
+	report := NewTaskReport(greetingTask)
+
+	cfg := domen.Config{Endpoint: srv.Endpoint(), MaxTaskAttempts: 1}
+	if err := runTask(t.Context(), greetingTask, cfg, NewChangeSet(), report, nil); err != nil {
+		t.Fatalf("runTask() error = %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("runTask() made %d requests, want 3", len(requests))
+	}
+	if !containsMessage(requests[1].Messages, "user", "prog/main.go:5:1: expected operand, found '}' (syntax)") {
+		t.Errorf("fix request does not contain the syntax diagnostic")
+	}
+	if got := report.Stages[StageCompile]; got == nil || *got != (StageReport{Attempts: 1, Passed: true}) {
+		t.Errorf("compile stage = %+v", got)
+	}
+}
+
+func TestWorkspace_Execute_syntax(t *testing.T) {
+	newSandbox(t)
+	ws, err := NewWorkspace(domen.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Changes = NewChangeSet()
+	tests := []struct {
+		name    string
+		cmd     domen.Command
+		wantErr bool
+	}{
+		{name: "broken go file", cmd: domen.Command{Type: "create", Path: "prog/main.go", Content: brokenMainCode}, wantErr: true},
+		{name: "not a go file", cmd: domen.Command{Type: "create", Path: "prog/notes.txt", Content: brokenMainCode}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ws.Execute(t.Context(), tt.cmd)
+			var syntaxErr *SyntaxError
+			if got := errors.As(err, &syntaxErr); got != tt.wantErr {
+				t.Fatalf("Execute() error = %v, want syntax error %v", err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(tt.cmd.Path); tt.wantErr != os.IsNotExist(statErr) {
+				t.Errorf("%s exists = %v, want %v", tt.cmd.Path, statErr == nil, !tt.wantErr)
+			}
+		})
+	}
+	if paths := ws.Changes.Paths(); len(paths) != 1 {
+		t.Errorf("changes = %v, want only the written file", paths)
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"Ralf/internal/i18n"
+	"Ralf/internal/prompts"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultCharsPerToken — среднее число символов на токен для смеси кода Go и русского текста.
+	defaultCharsPerToken = 3.0
+	// defaultContextSize — размер контекста для моделей, которых нет в таблице.
+	defaultContextSize = 32768
+	// messageOverheadTokens — служебные токены на каждое сообщение (роль, разделители).
+	messageOverheadTokens = 4
+)
+
+// modelContextSizes — размеры контекстных окон известных моделей (в токенах).
+var modelContextSizes = map[string]int{
+	"local-model":                     32768,
+	"qwen2.5-coder-7b-instruct":       32768,
+	"qwen2.5-coder-14b-instruct":      32768,
+	"qwen2.5-coder-32b-instruct":      32768,
+	"deepseek-coder-6.7b-instruct":    16384,
+	"deepseek-coder-v2-lite-instruct": 131072,
+	"codellama-7b-instruct":           16384,
+	"meta-llama-3.1-8b-instruct":      131072,
+	"mistral-7b-instruct-v0.3":        32768,
+}
+
+// ModelContextSize возвращает размер контекста модели в токенах.
+func ModelContextSize(model string) int {
+	if size, ok := modelContextSizes[strings.ToLower(model)]; ok {
+		return size
+	}
+	return defaultContextSize
+}
+
+// TokenCounter оценивает количество токенов в тексте.
+// Позволяет подключить настоящий токенизатор вместо эвристики.
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// HeuristicCounter оценивает количество токенов по числу символов в тексте.
+type HeuristicCounter struct {
+	CharsPerToken float64
+}
+
+// CountTokens возвращает оценку количества токенов (с округлением вверх).
+func (h HeuristicCounter) CountTokens(text string) int {
+	cpt := h.CharsPerToken
+	if cpt <= 0 {
+		cpt = defaultCharsPerToken
+	}
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / cpt))
+}
+
+// countMessagesTokens возвращает оценку токенов для набора сообщений.
+func countMessagesTokens(counter TokenCounter, messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += counter.CountTokens(m.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// Ступени сокращения запроса на исправление: сначала лог, затем окна кода, затем история.
+var (
+	logLineSteps    = []int{200, 80, 40, 20, 10, 5}
+	codeRadiusSteps = []int{40, 20, 10, 5, 2}
+)
+
+// fitFixPrompt собирает сообщения для запроса на исправление так, чтобы они
+// поместились в бюджет контекста. Сокращение идёт в фиксированном порядке:
+// сначала лог компиляции, затем код (остаются окна вокруг строк с ошибками),
+// и только потом история задачи.
+func fitFixPrompt(counter TokenCounter, budget int, system string, history *TaskHistory,
+	p prompts.CompileFixData, render func(prompts.CompileFixData) (string, error)) ([]Message, error) {
+	fullHistory := countMessagesTokens(counter, history.Messages(math.MaxInt, counter))
+	var renderErr error
+	fits := func() bool {
+		prompt, err := render(p)
+		if err != nil {
+			renderErr = err
+			return true
+		}
+		return counter.CountTokens(system)+counter.CountTokens(prompt)+2*messageOverheadTokens+fullHistory <= budget
+	}
+
+	originalLog := p.Log
+	for _, limit := range logLineSteps {
+		if fits() {
+			break
+		}
+		p.Log = truncateLog(originalLog, limit)
+	}
+
+	originalCode := p.Code
+	errLines := errorLines(originalLog, p.Path)
+	for _, radius := range codeRadiusSteps {
+		if fits() {
+			break
+		}
+		p.Code = codeWindows(originalCode, errLines, radius)
+	}
+	if renderErr != nil {
+		return nil, renderErr
+	}
+
+	prompt, err := render(p)
+	if err != nil {
+		return nil, err
+	}
+	remaining := budget - counter.CountTokens(system) - counter.CountTokens(prompt) - 2*messageOverheadTokens
+	messages := []Message{{Role: "system", Content: system}}
+	messages = append(messages, history.Messages(remaining, counter)...)
+	return append(messages, Message{Role: "user", Content: prompt}), nil
+}
+
+// truncateLog оставляет первые maxLines строк лога (в go build самые важные ошибки идут первыми).
+func truncateLog(log string, maxLines int) string {
+	lines := strings.Split(strings.TrimRight(log, "\n"), "\n")
+	if len(lines) <= maxLines {
+		return log
+	}
+	return strings.Join(lines[:maxLines], "\n") +
+		fmt.Sprintf(i18n.T("\n... (пропущено строк лога: %d)"), len(lines)-maxLines)
+}
+
+// goErrorLine находит ссылки вида file.go:12:5 в логе компиляции.
+var goErrorLine = regexp.MustCompile(`([^\s:]+\.go):(\d+)(?::\d+)?`)
+
+// errorLines возвращает отсортированные номера строк файла path, упомянутые в логе.
+func errorLines(log, path string) []int {
+	seen := make(map[int]bool)
+	var result []int
+	for _, m := range goErrorLine.FindAllStringSubmatch(log, -1) {
+		if !strings.HasSuffix(path, m[1]) && !strings.HasSuffix(m[1], path) {
+			continue
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil || seen[n] {
+			continue
+		}
+		seen[n] = true
+		result = append(result, n)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// codeWindows оставляет от кода только окна радиусом radius вокруг строк с ошибками.
+// Строки нумеруются, пропуски помечаются. Если строки с ошибками неизвестны,
+// остаётся начало файла.
+func codeWindows(code string, errLines []int, radius int) string {
+	lines := strings.Split(code, "\n")
+	if len(errLines) == 0 {
+		errLines = []int{1}
+	}
+
+	keep := make([]bool, len(lines))
+	for _, n := range errLines {
+		for i := n - 1 - radius; i <= n-1+radius; i++ {
+			if i >= 0 && i < len(lines) {
+				keep[i] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	skipped := false
+	for i, line := range lines {
+		if !keep[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			sb.WriteString("// ...\n")
+			skipped = false
+		}
+		sb.WriteString(fmt.Sprintf("%d: %s\n", i+1, line))
+	}
+	if skipped {
+		sb.WriteString("// ...\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"Ralf/internal/i18n"
+	"Ralf/internal/prompts"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeuristicCounter_CountTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		counter HeuristicCounter
+		text    string
+		want    int
+	}{
+		{name: "empty", counter: HeuristicCounter{CharsPerToken: 4}, text: "", want: 0},
+		{name: "round up", counter: HeuristicCounter{CharsPerToken: 4}, text: "abcde", want: 2},
+		{name: "runes not bytes", counter: HeuristicCounter{CharsPerToken: 2}, text: "тест", want: 2},
+		{name: "default ratio", counter: HeuristicCounter{}, text: "abcdef", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.counter.CountTokens(tt.text); got != tt.want {
+				t.Errorf("CountTokens() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_errorLines(t *testing.T) {
+	log := `# prog
+prog/main.go:12:5: undefined: foo
+prog/main.go:3:2: "fmt" imported and not used
+prog/other.go:7:1: syntax error
+prog/main.go:12:9: undefined: bar`
+	want := []int{3, 12}
+	if got := errorLines(log, "prog/main.go"); !reflect.DeepEqual(got, want) {
+		t.Errorf("errorLines() = %v, want %v", got, want)
+	}
+}
+
+func Test_codeWindows(t *testing.T) {
+	code := "l1\nl2\nl3\nl4\nl5\nl6\nl7"
+	want := "// ...\n3: l3\n4: l4\n5: l5\n// ...\n"
+	if got := codeWindows(code, []int{4}, 1); got != want {
+		t.Errorf("codeWindows() = %q, want %q", got, want)
+	}
+}
+
+func Test_fitFixPrompt(t *testing.T) {
+	var logLines []string
+	for i := 0; i < 300; i++ {
+		logLines = append(logLines, "prog/main.go:50:1: undefined: something")
+	}
+	history := NewTaskHistory()
+	history.AddPrompt("задача")
+	history.AddCommands(strings.Repeat("c", 2000))
+	history.AddDiagnostic(strings.Repeat("d", 2000))
+	history.AddCommands(strings.Repeat("e", 100))
+
+	var codeLines []string
+	for i := 0; i < 200; i++ {
+		codeLines = append(codeLines, "\tx := computeSomethingLong(argument)")
+	}
+	set, err := prompts.Load("", i18n.Russian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := set.CompileFix
+	mustRender := func(p prompts.CompileFixData) string {
+		prompt, err := render(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prompt
+	}
+	fit := func(budget int, p prompts.CompileFixData) []Message {
+		messages, err := fitFixPrompt(testCounter, budget, "system", history, p, render)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return messages
+	}
+	p := prompts.CompileFixData{
+		Attempt: 1,
+		Path:    "prog/main.go",
+		Code:    strings.Join(codeLines, "\n"),
+		Log:     strings.Join(logLines, "\n"),
+	}
+
+	t.Run("everything fits", func(t *testing.T) {
+		messages := fit(1000000, p)
+		if len(messages) != 6 {
+			t.Fatalf("fitFixPrompt() messages = %d, want 6", len(messages))
+		}
+		if messages[5].Content != mustRender(p) {
+			t.Errorf("fitFixPrompt() changed prompt although it fits")
+		}
+	})
+
+	t.Run("log shrinks before code and history", func(t *testing.T) {
+		p := p
+		p.Log = strings.Join(logLines[:100], "\n")
+		budget := countMessagesTokens(testCounter, history.Messages(1000000, testCounter)) +
+			testCounter.CountTokens("system") + testCounter.CountTokens(mustRender(p)) + 2*messageOverheadTokens
+		p.Log = strings.Join(logLines, "\n")
+
+		messages := fit(budget, p)
+		prompt := messages[len(messages)-1].Content
+		if len(messages) != 6 {
+			t.Errorf("fitFixPrompt() trimmed history, messages = %d, want 6", len(messages))
+		}
+		if !strings.Contains(prompt, "пропущено строк лога") {
+			t.Errorf("fitFixPrompt() did not truncate log")
+		}
+		if !strings.Contains(prompt, p.Code) {
+			t.Errorf("fitFixPrompt() shrank code before log was enough")
+		}
+	})
+
+	t.Run("history trimmed last", func(t *testing.T) {
+		messages := fit(1500, p)
+		prompt := messages[len(messages)-1].Content
+		if strings.Contains(prompt, p.Code) {
+			t.Errorf("fitFixPrompt() kept full code")
+		}
+		if len(messages) >= 6 {
+			t.Errorf("fitFixPrompt() kept full history, messages = %d", len(messages))
+		}
+		if messages[1].Content != "задача" {
+			t.Errorf("fitFixPrompt() dropped the task message")
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"Ralf/domen"
+	"Ralf/internal/i18n"
+	"Ralf/internal/logging"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Workspace — каталоги, относительно которых выполняются все этапы обработки
+// задачи: сборка, тесты, команды модели и контекст проекта.
+type Workspace struct {
+	Root           string        // абсолютный путь к корню Go-модуля проекта
+	OutputDir      string        // каталог для кода модели относительно Root (в слэш-нотации)
+	Sandbox        string        // абсолютный путь, за пределы которого команды модели не выходят
+	CompileTimeout time.Duration // таймаут одного запуска go build / go test (0 — без ограничения)
+	Changes        *ChangeSet    // файлы, затронутые командами модели (nil — не отслеживать)
+	Format         string        // обработка записанных .go-файлов: off, on или imports (пусто — off)
+	Excluded       []string      // файлы и каталоги Ralf внутри проекта (кассеты, отчёты, журнал промптов) относительно Root
+	Log            *slog.Logger  // журнал команд (nil — slog.Default)
+}
+
+// NewWorkspace строит рабочее пространство из настроек: корень проекта — WorkingDir,
+// целевой каталог — OutputDir внутри него, песочница — SandboxRoot или корень проекта.
+func NewWorkspace(cfg domen.Config) (Workspace, error) {
+	cfg = cfg.WithDefaults()
+	root, err := filepath.Abs(cfg.WorkingDir)
+	if err != nil {
+		return Workspace{}, fmt.Errorf(i18n.T("неверный каталог проекта %s: %w"), cfg.WorkingDir, err)
+	}
+	sandbox := root
+	if cfg.SandboxRoot != "" {
+		if sandbox, err = filepath.Abs(cfg.SandboxRoot); err != nil {
+			return Workspace{}, fmt.Errorf(i18n.T("неверный каталог песочницы %s: %w"), cfg.SandboxRoot, err)
+		}
+	}
+	w := Workspace{
+		Root:           root,
+		OutputDir:      filepath.ToSlash(filepath.Clean(cfg.OutputDir)),
+		Sandbox:        sandbox,
+		CompileTimeout: cfg.CompileTimeout,
+		Format:         cfg.Format,
+	}
+	if _, err := w.Resolve(w.OutputDir); err != nil {
+		return Workspace{}, err
+	}
+	// служебные файлы запуска не должны попадать в контекст проекта: иначе
+	// запрос к модели зависел бы от того, сколько раз Ralf уже запускали
+	for _, path := range []string{cfg.CassetteDir, cfg.ReportDir, cfg.PromptLog} {
+		if path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			w.Excluded = append(w.Excluded, filepath.ToSlash(rel))
+		}
+	}
+	return w, nil
+}
+
+// Target возвращает абсолютный путь к целевому каталогу.
+func (w Workspace) Target() string {
+	return filepath.Join(w.Root, filepath.FromSlash(w.OutputDir))
+}
+
+// Path возвращает путь к файлу целевого каталога относительно корня проекта
+// (в таком виде пути видит модель).
+func (w Workspace) Path(name string) string {
+	return filepath.ToSlash(filepath.Join(w.OutputDir, name))
+}
+
+// Rel переводит абсолютные пути в пути относительно корня проекта (в слэш-нотации).
+func (w Workspace) Rel(paths []string) []string {
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if r, err := filepath.Rel(w.Root, p); err == nil {
+			p = r
+		}
+		result = append(result, filepath.ToSlash(p))
+	}
+	return result
+}
+
+// Resolve переводит путь из команды модели в абсолютный: относительные пути
+// отсчитываются от корня проекта. Путь за пределами песочницы — ошибка.
+func (w Workspace) Resolve(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	abs := filepath.FromSlash(path)
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(w.Root, abs)
+	}
+	abs = filepath.Clean(abs)
+	rel, err := filepath.Rel(w.Sandbox, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf(i18n.T("путь %s выходит за пределы каталога %s"), path, w.Sandbox)
+	}
+	return abs, nil
+}
+
+// logger возвращает журнал рабочего пространства.
+func (w Workspace) logger() *slog.Logger {
+	if w.Log != nil {
+		return w.Log
+	}
+	return slog.Default()
+}
+
+// Execute выполняет команду модели, предварительно разрешив её пути.
+func (w Workspace) Execute(ctx context.Context, cmd domen.Command) (string, error) {
+	typ, _ := mapCommandType(cmd.Type)
+	name, ok := domen.EnglishCommandTypes[typ]
+	if !ok {
+		name = cmd.Type
+	}
+	path := cmd.Path
+	if path == "" {
+		path = cmd.DstPath
+	}
+	w.logger().Debug(i18n.T("Выполняем команду модели"), logging.KeyCommand, name, logging.KeyPath, path)
+
+	var err error
+	if cmd.Path, err = w.Resolve(cmd.Path); err != nil {
+		return "", err
+	}
+	if cmd.SrcPath, err = w.Resolve(cmd.SrcPath); err != nil {
+		return "", err
+	}
+	if cmd.DstPath, err = w.Resolve(cmd.DstPath); err != nil {
+		return "", err
+	}
+	if typ == domen.CmdCompileCode {
+		// компиляция всегда идёт по всему модулю из его корня
+		return w.Compile(ctx)
+	}
+	// код, который не разбирается, не попадает на диск и не ломает сборку других пакетов
+	if err := checkSyntax(cmd, typ, w.Rel([]string{cmd.Path})[0]); err != nil {
+		w.logger().Warn(i18n.T("Код модели не разбирается, файл не записан"), logging.KeyPath, path, logging.KeyError, err)
+		return "", err
+	}
+	// путь запоминается и при ошибке: команда могла успеть изменить файл
+	w.Changes.Add(touchedPaths(cmd)...)
+	return ExecuteCommand(ctx, cmd)
+}
+
+// ExecuteAll выполняет команды одного ответа модели по порядку и только потом
+// форматирует записанные ими .go-файлы: номера строк в командах ответа
+// относятся к файлам до форматирования. errs — ошибки команд в порядке
+// выполнения, format — синтаксические ошибки, найденные форматированием.
+func (w Workspace) ExecuteAll(ctx context.Context, commands []domen.Command) (errs []error, format []Diagnostic) {
+	var written []string
+	for _, cmd := range commands {
+		if _, err := w.Execute(ctx, cmd); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		typ, _ := mapCommandType(cmd.Type)
+		switch typ {
+		case domen.CmdCreate, domen.CmdEdit, domen.CmdAddLines, domen.CmdDeleteLines:
+			if abs, err := w.Resolve(cmd.Path); err == nil && !slices.Contains(written, abs) {
+				written = append(written, abs)
+			}
+		}
+	}
+	return errs, w.formatFiles(ctx, written)
+}
+
+// ReadFile читает файл проекта по пути из команды модели.
+func (w Workspace) ReadFile(path string) ([]byte, error) {
+	abs, err := w.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(abs)
+}
+
+// Compile собирает весь модуль проекта из его корня.
+func (w Workspace) Compile(ctx context.Context) (string, error) {
+	return compileDir(ctx, w.Root, w.CompileTimeout)
+}
+
+// CompileTests компилирует тесты модуля проекта без запуска.
+func (w Workspace) CompileTests(ctx context.Context) (string, error) {
+	return compileTestsDir(ctx, w.Root, w.CompileTimeout)
+}
+
+// CheckAccess проверяет, что в целевом каталоге можно создавать файлы.
+func (w Workspace) CheckAccess() error {
+	if err := os.MkdirAll(w.Target(), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(w.Target(), ".ralf-check-")
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(tmp)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"Ralf/domen"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspace_Resolve(t *testing.T) {
+	root := t.TempDir()
+	ws, err := NewWorkspace(domen.Config{WorkingDir: root, OutputDir: "gen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wide, err := NewWorkspace(domen.Config{WorkingDir: root, SandboxRoot: filepath.Dir(root)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ws      Workspace
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "relative", ws: ws, path: "gen/main.go", want: filepath.Join(root, "gen", "main.go")},
+		{name: "absolute inside", ws: ws, path: filepath.Join(root, "go.mod"), want: filepath.Join(root, "go.mod")},
+		{name: "dot dot inside", ws: ws, path: "gen/../go.mod", want: filepath.Join(root, "go.mod")},
+		{name: "escape", ws: ws, path: "../outside.go", wantErr: true},
+		{name: "absolute outside", ws: ws, path: filepath.Join(filepath.Dir(root), "x.go"), wantErr: true},
+		{name: "wider sandbox", ws: wide, path: "../sibling/x.go", want: filepath.Join(filepath.Dir(root), "sibling", "x.go")},
+		{name: "empty", ws: ws, path: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.ws.Resolve(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewWorkspace(domen.Config{WorkingDir: root, OutputDir: "../elsewhere"}); err == nil {
+		t.Errorf("NewWorkspace() with output dir outside the sandbox: want error")
+	}
+}
+
+func TestWorkspace_ExecuteAndCompile(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module sandbox\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := NewWorkspace(domen.Config{WorkingDir: root, OutputDir: "gen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.CheckAccess(); err != nil {
+		t.Fatalf("CheckAccess() error = %v", err)
+	}
+
+	if _, err := ws.Execute(t.Context(), domen.Command{Type: "create", Path: ws.Path("main.go"), Content: badMainCode}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "gen", "main.go")); err != nil {
+		t.Fatalf("file is not created inside the project root: %v", err)
+	}
+	if log, err := ws.Compile(t.Context()); err == nil || log == "" {
+		t.Errorf("Compile() = %q, %v; want compile error", log, err)
+	}
+
+	if _, err := ws.Execute(t.Context(), domen.Command{Type: "edit", Path: "gen/main.go", Content: goodMainCode}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if log, err := ws.Compile(t.Context()); err != nil {
+		t.Errorf("Compile() error = %v\n%s", err, log)
+	}
+
+	if _, err := ws.Execute(t.Context(), domen.Command{Type: "create", Path: "../escape.go", Content: "package x"}); err == nil {
+		t.Errorf("Execute() outside the sandbox: want error")
+	}
+}